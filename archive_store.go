@@ -0,0 +1,408 @@
+package pg_mini
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// archiveKinds are the supported archive file extensions.
+var archiveKinds = []string{".tar.zst", ".tar", ".zip"}
+
+// IsArchivePath reports whether path names an archive ArchiveStore can open.
+func IsArchivePath(path string) bool {
+	return archiveKind(path) != ""
+}
+
+func archiveKind(path string) string {
+	for _, kind := range archiveKinds {
+		if strings.HasSuffix(path, kind) {
+			return kind
+		}
+	}
+	return ""
+}
+
+// ArchiveStore is a Store backed by a single .tar, .tar.zst or .zip file,
+// handy for sharing a backup as one artifact. An archive is either written
+// once, by CreateArchive, or read, by OpenArchive; it is never modified in
+// place. Close must be called in either case.
+type ArchiveStore struct {
+	path string
+	kind string
+
+	// Written archives
+	mu     sync.Mutex
+	tmp    string // the archive being written, renamed to path on Close
+	out    *archiveWriter
+	names  []string // members in archive order
+	closed bool
+
+	// Read archives
+	f       *os.File
+	zr      *zip.Reader
+	members map[string]tarMember // tar and .tar.zst members by name
+}
+
+// tarMember locates a member of a .tar file; .tar.zst members are found by
+// scanning, so only their presence is recorded.
+type tarMember struct {
+	offset int64
+	size   int64
+}
+
+// CreateArchive starts a new archive at path, replacing any archive there
+// once Close succeeds; Discard abandons it instead. The kind is picked by
+// extension. Each member is spooled to a temp file while written and appended
+// to the archive when its writer is closed. Members cannot be read back or
+// written twice.
+func CreateArchive(path string) (*ArchiveStore, error) {
+	kind := archiveKind(path)
+	if kind == "" {
+		return nil, fmt.Errorf("unsupported archive %s: must end in %s", path, strings.Join(archiveKinds, ", "))
+	}
+
+	// Write next to the archive and swap on Close, so a failed export leaves
+	// a previous archive intact
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("create archive: %w", err)
+	}
+	out, err := newArchiveWriter(f, kind)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return &ArchiveStore{path: path, kind: kind, tmp: f.Name(), out: out}, nil
+}
+
+// OpenArchive opens the archive at path for reading. Members are streamed
+// from the archive on Open; nothing is extracted to disk. Zip and plain tar
+// members are read directly, while a .tar.zst is decompressed from the start
+// up to the member on every Open. Create fails; see OverlayStore to keep the
+// files an import writes next to the archive.
+func OpenArchive(path string) (*ArchiveStore, error) {
+	kind := archiveKind(path)
+	if kind == "" {
+		return nil, fmt.Errorf("unsupported archive %s: must end in %s", path, strings.Join(archiveKinds, ", "))
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open archive: %w", err)
+	}
+	s := &ArchiveStore{path: path, kind: kind, f: f}
+	if err := s.index(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("read archive %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// index records the members of a read archive.
+func (s *ArchiveStore) index() error {
+	if s.kind == ".zip" {
+		info, err := s.f.Stat()
+		if err != nil {
+			return err
+		}
+		s.zr, err = zip.NewReader(s.f, info.Size())
+		return err
+	}
+
+	s.members = map[string]tarMember{}
+	tr, closeTar, err := s.tarReader()
+	if err != nil {
+		return err
+	}
+	defer closeTar()
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		member := tarMember{size: hdr.Size}
+		if s.kind == ".tar" {
+			// tar reads whole blocks without buffering, so the file offset
+			// is where the member's data starts
+			if member.offset, err = s.f.Seek(0, io.SeekCurrent); err != nil {
+				return err
+			}
+		}
+		s.members[hdr.Name] = member
+	}
+}
+
+// tarReader reads a tar or .tar.zst archive from the start.
+func (s *ArchiveStore) tarReader() (*tar.Reader, func(), error) {
+	if s.kind == ".tar" {
+		if _, err := s.f.Seek(0, io.SeekStart); err != nil {
+			return nil, nil, err
+		}
+		return tar.NewReader(s.f), func() {}, nil
+	}
+
+	// Each scan gets its own file handle, so concurrent Opens do not share
+	// a position
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, nil, err
+	}
+	zr, err := zstd.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return tar.NewReader(zr), func() { zr.Close(); f.Close() }, nil
+}
+
+// Create spools name to a temp file. The member is added to the archive when
+// the writer is closed.
+func (s *ArchiveStore) Create(name string) (io.WriteCloser, error) {
+	if s.out == nil {
+		return nil, fmt.Errorf("create %s: archive %s is open for reading", name, s.path)
+	}
+	if !filepath.IsLocal(filepath.FromSlash(name)) {
+		return nil, fmt.Errorf("invalid archive member name %q", name)
+	}
+	f, err := os.CreateTemp("", "pg_mini-member-*")
+	if err != nil {
+		return nil, fmt.Errorf("create %s: %w", name, err)
+	}
+	return &archiveMember{s: s, name: name, f: f}, nil
+}
+
+// Open streams a member of a read archive. The error for a missing member
+// wraps fs.ErrNotExist.
+func (s *ArchiveStore) Open(name string) (io.ReadCloser, error) {
+	if s.out != nil {
+		s.mu.Lock()
+		written := slices.Contains(s.names, name)
+		s.mu.Unlock()
+		if written {
+			return nil, fmt.Errorf("open %s: archive %s is being written, members cannot be read back", name, s.path)
+		}
+		return nil, fmt.Errorf("open %s: %w", name, fs.ErrNotExist)
+	}
+
+	if s.zr != nil {
+		f, err := s.zr.Open(name)
+		if err != nil {
+			return nil, fmt.Errorf("open %s: %w", name, err)
+		}
+		return f, nil
+	}
+
+	member, ok := s.members[name]
+	if !ok {
+		return nil, fmt.Errorf("open %s: %w", name, fs.ErrNotExist)
+	}
+	if s.kind == ".tar" {
+		return io.NopCloser(io.NewSectionReader(s.f, member.offset, member.size)), nil
+	}
+
+	tr, closeTar, err := s.tarReader()
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", name, err)
+	}
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			closeTar()
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, fmt.Errorf("open %s: %w", name, err)
+		}
+		if hdr.Typeflag == tar.TypeReg && hdr.Name == name {
+			return &archiveReader{Reader: tr, close: closeTar}, nil
+		}
+	}
+}
+
+// Close finishes a written archive and moves it into place, or releases a
+// read one.
+func (s *ArchiveStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	if s.out == nil {
+		return s.f.Close()
+	}
+	if err := s.out.close(); err != nil {
+		os.Remove(s.tmp)
+		return err
+	}
+	if err := os.Rename(s.tmp, s.path); err != nil {
+		os.Remove(s.tmp)
+		return fmt.Errorf("replace archive: %w", err)
+	}
+	return nil
+}
+
+// Discard abandons a written archive, leaving any archive already at the path
+// untouched, or releases a read one like Close. It does nothing after Close.
+func (s *ArchiveStore) Discard() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	if s.out == nil {
+		return s.f.Close()
+	}
+	s.out.close()
+	if err := os.Remove(s.tmp); err != nil {
+		return fmt.Errorf("discard archive: %w", err)
+	}
+	return nil
+}
+
+// commit appends a closed member to a written archive.
+func (s *ArchiveStore) commit(name, path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return fmt.Errorf("archive %s is closed", s.path)
+	}
+	if slices.Contains(s.names, name) {
+		return fmt.Errorf("add %s: already in archive %s", name, s.path)
+	}
+	if err := s.out.add(name, path); err != nil {
+		return err
+	}
+	s.names = append(s.names, name)
+	return nil
+}
+
+// archiveMember is a member being spooled to a temp file.
+type archiveMember struct {
+	s    *ArchiveStore
+	name string
+	f    *os.File
+}
+
+func (m *archiveMember) Write(p []byte) (int, error) {
+	return m.f.Write(p)
+}
+
+func (m *archiveMember) Close() error {
+	defer os.Remove(m.f.Name())
+	if err := m.f.Close(); err != nil {
+		return err
+	}
+	return m.s.commit(m.name, m.f.Name())
+}
+
+// archiveReader streams a .tar.zst member, closing the decompressor with it.
+type archiveReader struct {
+	io.Reader
+	close func()
+}
+
+func (r *archiveReader) Close() error {
+	r.close()
+	return nil
+}
+
+// archiveWriter writes members to a tar, zstd compressed tar or zip file.
+type archiveWriter struct {
+	f   *os.File
+	zst *zstd.Encoder
+	tw  *tar.Writer
+	zw  *zip.Writer
+}
+
+func newArchiveWriter(f *os.File, kind string) (*archiveWriter, error) {
+	a := &archiveWriter{f: f}
+	switch kind {
+	case ".zip":
+		a.zw = zip.NewWriter(f)
+	case ".tar.zst":
+		zst, err := zstd.NewWriter(f)
+		if err != nil {
+			return nil, fmt.Errorf("create zstd writer: %w", err)
+		}
+		a.zst = zst
+		a.tw = tar.NewWriter(zst)
+	default:
+		a.tw = tar.NewWriter(f)
+	}
+	return a, nil
+}
+
+// add copies the file at path into the archive as name.
+func (a *archiveWriter) add(name, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer
+	if a.zw != nil {
+		w, err = a.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: info.ModTime()})
+	} else {
+		w, err = a.tw, a.tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     0o644,
+			Size:     info.Size(),
+			ModTime:  info.ModTime().Truncate(time.Second),
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("add %s: %w", name, err)
+	}
+	if _, err := io.Copy(w, f); err != nil {
+		return fmt.Errorf("add %s: %w", name, err)
+	}
+	return nil
+}
+
+func (a *archiveWriter) close() error {
+	var err error
+	if a.zw != nil {
+		err = a.zw.Close()
+	} else {
+		err = a.tw.Close()
+	}
+	if a.zst != nil {
+		err = errors.Join(err, a.zst.Close())
+	}
+	err = errors.Join(err, a.f.Close())
+	if err != nil {
+		return fmt.Errorf("finish archive: %w", err)
+	}
+	return nil
+}
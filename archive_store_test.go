@@ -0,0 +1,280 @@
+package pg_mini
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeMember(t *testing.T, s Store, name, data string) {
+	t.Helper()
+	w, err := s.Create(name)
+	if err != nil {
+		t.Fatalf("create %s: %v", name, err)
+	}
+	if _, err := io.WriteString(w, data); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close %s: %v", name, err)
+	}
+}
+
+func readMember(t *testing.T, s Store, name string) string {
+	t.Helper()
+	r, err := s.Open(name)
+	if err != nil {
+		t.Fatalf("open %s: %v", name, err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("read %s: %v", name, err)
+	}
+	return string(data)
+}
+
+func TestArchiveStore_RoundTrip(t *testing.T) {
+	for _, kind := range archiveKinds {
+		t.Run(kind, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "backup"+kind)
+
+			s, err := CreateArchive(path)
+			if err != nil {
+				t.Fatalf("create archive: %v", err)
+			}
+			writeMember(t, s, "schema.json", `{"Tables":{}}`)
+			writeMember(t, s, "public.users.csv", "id\n\"1\"\n")
+			writeMember(t, s, "nested/big.csv", strings.Repeat("x", 100_000))
+			if _, err := s.Open("schema.json"); err == nil {
+				t.Fatal("expected an error reading a member before close")
+			}
+			if _, err := s.Open("manifest.json"); !errors.Is(err, fs.ErrNotExist) {
+				t.Fatalf("open unwritten: got %v, want fs.ErrNotExist", err)
+			}
+			if w, err := s.Create("schema.json"); err == nil {
+				if err := w.Close(); err == nil {
+					t.Fatal("expected an error writing a member twice")
+				}
+			}
+			if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+				t.Fatalf("archive should appear on close, stat: %v", err)
+			}
+			if err := s.Close(); err != nil {
+				t.Fatalf("close archive: %v", err)
+			}
+
+			s, err = OpenArchive(path)
+			if err != nil {
+				t.Fatalf("open archive: %v", err)
+			}
+			defer s.Close()
+			// Out of archive order, and twice
+			want := map[string]string{
+				"nested/big.csv":   strings.Repeat("x", 100_000),
+				"public.users.csv": "id\n\"1\"\n",
+				"schema.json":      `{"Tables":{}}`,
+			}
+			for _, name := range []string{"public.users.csv", "schema.json", "nested/big.csv", "public.users.csv"} {
+				if got := readMember(t, s, name); got != want[name] {
+					t.Errorf("%s: got %d bytes, want %d", name, len(got), len(want[name]))
+				}
+			}
+			if _, err := s.Open("missing.csv"); !errors.Is(err, fs.ErrNotExist) {
+				t.Fatalf("open missing: got %v, want fs.ErrNotExist", err)
+			}
+			if _, err := s.Create("import_report.json"); err == nil {
+				t.Fatal("expected an error writing to an archive opened for reading")
+			}
+		})
+	}
+}
+
+func TestArchiveStore_ReplaceOnClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backup.tar.zst")
+	s, err := CreateArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeMember(t, s, "schema.json", "old")
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// An unfinished export leaves the previous archive readable
+	s, err = CreateArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeMember(t, s, "schema.json", "new")
+	old, err := OpenArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readMember(t, old, "schema.json"); got != "old" {
+		t.Errorf("before close: got %q", got)
+	}
+	old.Close()
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	s, err = OpenArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if got := readMember(t, s, "schema.json"); got != "new" {
+		t.Errorf("after close: got %q", got)
+	}
+	if tmps, _ := filepath.Glob(path + ".*.tmp"); len(tmps) != 0 {
+		t.Errorf("temp files left: %v", tmps)
+	}
+}
+
+func TestArchiveStore_Discard(t *testing.T) {
+	for _, kind := range archiveKinds {
+		t.Run(kind, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "backup"+kind)
+			s, err := CreateArchive(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			writeMember(t, s, "schema.json", "old")
+			writeMember(t, s, "company.csv", "id\n\"1\"\n")
+			if err := s.Close(); err != nil {
+				t.Fatal(err)
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// A failed export has written some members when it gives up
+			s, err = CreateArchive(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			writeMember(t, s, "schema.json", "new")
+			if err := s.Discard(); err != nil {
+				t.Fatalf("discard: %v", err)
+			}
+			if err := s.Close(); err != nil {
+				t.Fatalf("close after discard: %v", err)
+			}
+
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Error("discarded export changed the existing archive")
+			}
+			if tmps, _ := filepath.Glob(path + ".*.tmp"); len(tmps) != 0 {
+				t.Errorf("temp files left: %v", tmps)
+			}
+		})
+	}
+}
+
+// TestE2E_ArchiveFailedExport checks that an export that fails midway, on a
+// root table that does not exist, leaves an existing archive unchanged once
+// discarded.
+func TestE2E_ArchiveFailedExport(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test in short mode")
+	}
+
+	connStr := startPostgres(t)
+	ctx := context.Background()
+	execSQLFile(t, connect(t, connStr), "testdata/e2e/company/setup.sql")
+
+	path := filepath.Join(t.TempDir(), "company.tar.zst")
+	export := func(root string) error {
+		archive, err := CreateArchive(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		exp := &Export{DB: connect(t, connStr), RootTable: root, Store: archive, NoAnimations: true}
+		if err := exp.Run(ctx); err != nil {
+			if derr := archive.Discard(); derr != nil {
+				t.Fatalf("discard: %v", derr)
+			}
+			return err
+		}
+		return archive.Close()
+	}
+
+	if err := export("company"); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := export("no_such_table"); err == nil {
+		t.Fatal("expected the export to fail")
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("failed export changed the existing archive")
+	}
+}
+
+func TestOverlayStore(t *testing.T) {
+	base, upper := NewMemStore(), NewMemStore()
+	writeMember(t, base, "schema.json", "base")
+	writeMember(t, base, "rejected.json", "base")
+
+	s := OverlayStore(base, upper)
+	writeMember(t, s, "rejected.json", "upper")
+
+	if got := readMember(t, s, "schema.json"); got != "base" {
+		t.Errorf("schema.json: got %q", got)
+	}
+	if got := readMember(t, s, "rejected.json"); got != "upper" {
+		t.Errorf("rejected.json: got %q", got)
+	}
+	if got := readMember(t, base, "rejected.json"); got != "base" {
+		t.Errorf("base modified: got %q", got)
+	}
+	if _, err := s.Open("missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing: got %v", err)
+	}
+}
+
+func TestArchiveStore_InvalidName(t *testing.T) {
+	s, err := CreateArchive(filepath.Join(t.TempDir(), "backup.tar"))
+	if err != nil {
+		t.Fatalf("create archive: %v", err)
+	}
+	defer s.Close()
+
+	if _, err := s.Create("../escape.csv"); err == nil {
+		t.Fatal("expected an error for a name outside the archive")
+	}
+}
+
+func TestIsArchivePath(t *testing.T) {
+	for path, want := range map[string]bool{
+		"backup.tar":     true,
+		"backup.tar.zst": true,
+		"out/backup.zip": true,
+		"backup":         false,
+		"s3://b/p":       false,
+		"backup.tar.gz":  false,
+	} {
+		if got := IsArchivePath(path); got != want {
+			t.Errorf("IsArchivePath(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package pg_mini
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/fritzkeyzer/pg_mini/azblob_store"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+var _ ExtendedStore = (*azblob_store.Store)(nil)
+
+// azuriteAccountKey is the well-known key of Azurite's devstoreaccount1.
+const azuriteAccountKey = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+
+// startAzurite boots an Azurite blob emulator container, creates the
+// container, and returns a Config pointing at it.
+func startAzurite(t *testing.T, container string) azblob_store.Config {
+	t.Helper()
+	ctx := context.Background()
+
+	c, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "mcr.microsoft.com/azure-storage/azurite:3.34.0",
+			Cmd:          []string{"azurite-blob", "--blobHost", "0.0.0.0", "--blobPort", "10000", "--skipApiVersionCheck"},
+			ExposedPorts: []string{"10000/tcp"},
+			WaitingFor:   wait.ForListeningPort("10000/tcp"),
+		},
+		Started: true,
+	})
+	if err != nil {
+		t.Fatalf("start azurite container: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := c.Terminate(ctx); err != nil {
+			t.Logf("terminate azurite container: %v", err)
+		}
+	})
+
+	endpoint, err := c.PortEndpoint(ctx, "10000/tcp", "http")
+	if err != nil {
+		t.Fatalf("get azurite endpoint: %v", err)
+	}
+
+	cfg := azblob_store.Config{
+		ConnectionString: fmt.Sprintf(
+			"DefaultEndpointsProtocol=http;AccountName=devstoreaccount1;AccountKey=%s;BlobEndpoint=%s/devstoreaccount1;",
+			azuriteAccountKey, endpoint,
+		),
+		Container: container,
+		// Small blocks exercise multi-block uploads
+		BlockSize: 1024 * 1024,
+	}
+
+	client, err := azblob.NewClientFromConnectionString(cfg.ConnectionString, nil)
+	if err != nil {
+		t.Fatalf("create azure blob client: %v", err)
+	}
+	if _, err := client.CreateContainer(ctx, container, nil); err != nil {
+		t.Fatalf("create container %s: %v", container, err)
+	}
+
+	return cfg
+}
+
+func TestE2E_AzblobRoundTrip(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test in short mode")
+	}
+
+	ctx := context.Background()
+	connStr := startPostgres(t)
+
+	setupConn := connect(t, connStr)
+	execSQLFile(t, setupConn, "testdata/e2e/company/setup.sql")
+	original := snapshotDB(t, setupConn)
+
+	// Export into Azurite under a name prefix.
+	cfg := startAzurite(t, "pg-mini-test")
+	cfg.Prefix = "backups/company"
+
+	exportStore, err := azblob_store.New(ctx, cfg)
+	if err != nil {
+		t.Fatalf("new azblob export store: %v", err)
+	}
+	exp := &Export{
+		DB:           connect(t, connStr),
+		RootTable:    "company",
+		Store:        exportStore,
+		NoAnimations: true,
+	}
+	if err := exp.Run(ctx); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	if _, err := exportStore.Stat("schema.json"); err != nil {
+		t.Fatalf("stat schema.json: %v", err)
+	}
+
+	truncateAll(t, connect(t, connStr))
+
+	// Import from a fresh Store, proving the blobs were durably written.
+	importStore, err := azblob_store.New(ctx, cfg)
+	if err != nil {
+		t.Fatalf("new azblob import store: %v", err)
+	}
+	imp := &Import{
+		DB:           connect(t, connStr),
+		RootTable:    "company",
+		Truncate:     true,
+		Store:        importStore,
+		NoAnimations: true,
+	}
+	if err := imp.Run(ctx); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+
+	restored := snapshotDB(t, connect(t, connStr))
+	compareSnapshots(t, original, restored)
+}
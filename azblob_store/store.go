@@ -0,0 +1,227 @@
+// Package azblob_store is an Azure Blob Storage implementation of the pg_mini
+// Store interface. Files are written as block blobs, streamed in blocks as
+// they are produced. It works against Azure and the Azurite emulator.
+package azblob_store
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+)
+
+// Store is a pg_mini Store backed by an Azure Blob Storage container. Blob
+// names are formed by joining Prefix with the requested name. Besides Create
+// and Open it implements List, Stat and Delete (pg_mini.ExtendedStore).
+type Store struct {
+	client      *azblob.Client
+	container   string
+	prefix      string
+	blockSize   int64
+	concurrency int
+	ctx         context.Context
+}
+
+// Config configures a Store. Credentials are picked in order: a connection
+// string, an account key, or else the default Azure credential chain
+// (environment, workload identity, managed identity, az login).
+type Config struct {
+	// ConnectionString, if set, supplies the endpoint and credentials, e.g.
+	// the one of the Azurite emulator.
+	ConnectionString string
+	// ServiceURL is the account endpoint, e.g.
+	// "https://myaccount.blob.core.windows.net/". Defaults to the public
+	// endpoint of AccountName.
+	ServiceURL string
+	// AccountName and AccountKey authenticate with a shared key. Without a
+	// key the default Azure credential chain is used.
+	AccountName string
+	AccountKey  string
+	// Container is the target container. It must already exist.
+	Container string
+	// Prefix is an optional name prefix (a virtual "directory") for all names.
+	Prefix string
+	// BlockSize is the size of each uploaded block in bytes and Concurrency
+	// how many blocks are uploaded at once; each in-flight block is buffered
+	// in memory. Zero values use the SDK defaults.
+	BlockSize   int64
+	Concurrency int
+}
+
+// New builds a Store from cfg. ctx bounds every subsequent read and write.
+func New(ctx context.Context, cfg Config) (*Store, error) {
+	serviceURL := cfg.ServiceURL
+	if serviceURL == "" && cfg.AccountName != "" {
+		serviceURL = fmt.Sprintf("https://%s.blob.core.windows.net/", cfg.AccountName)
+	}
+
+	var client *azblob.Client
+	var err error
+	switch {
+	case cfg.ConnectionString != "":
+		client, err = azblob.NewClientFromConnectionString(cfg.ConnectionString, nil)
+	case serviceURL == "":
+		return nil, fmt.Errorf("azure blob storage needs a connection string, service url or account name")
+	case cfg.AccountKey != "":
+		var cred *azblob.SharedKeyCredential
+		cred, err = azblob.NewSharedKeyCredential(cfg.AccountName, cfg.AccountKey)
+		if err == nil {
+			client, err = azblob.NewClientWithSharedKeyCredential(serviceURL, cred, nil)
+		}
+	default:
+		var cred *azidentity.DefaultAzureCredential
+		cred, err = azidentity.NewDefaultAzureCredential(nil)
+		if err == nil {
+			client, err = azblob.NewClient(serviceURL, cred, nil)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create azure blob client: %w", err)
+	}
+
+	return &Store{
+		client:      client,
+		container:   cfg.Container,
+		prefix:      cfg.Prefix,
+		blockSize:   cfg.BlockSize,
+		concurrency: cfg.Concurrency,
+		ctx:         ctx,
+	}, nil
+}
+
+func (s *Store) key(name string) string {
+	if s.prefix == "" {
+		return name
+	}
+	return s.prefix + "/" + name
+}
+
+// name is the inverse of key.
+func (s *Store) name(key string) string {
+	if s.prefix == "" {
+		return key
+	}
+	return strings.TrimPrefix(key, s.prefix+"/")
+}
+
+// Open returns a reader for the named blob.
+func (s *Store) Open(name string) (io.ReadCloser, error) {
+	resp, err := s.client.DownloadStream(s.ctx, s.container, s.key(name), nil)
+	if bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return nil, fmt.Errorf("open %s: %w (%w)", name, fs.ErrNotExist, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", name, err)
+	}
+	return resp.Body, nil
+}
+
+// Create returns a writer that streams to the named block blob and commits
+// the block list on Close. The write is only durable once Close returns
+// without error.
+func (s *Store) Create(name string) (io.WriteCloser, error) {
+	pr, pw := io.Pipe()
+	w := &blobWriter{pw: pw, done: make(chan error, 1)}
+
+	go func() {
+		_, err := s.client.UploadStream(s.ctx, s.container, s.key(name), pr, &azblob.UploadStreamOptions{
+			BlockSize:   s.blockSize,
+			Concurrency: s.concurrency,
+		})
+		pr.CloseWithError(err)
+		w.done <- err
+	}()
+
+	return w, nil
+}
+
+// List returns the names of all blobs under the store prefix that start
+// with prefix, sorted.
+func (s *Store) List(prefix string) ([]string, error) {
+	var names []string
+	key := s.key(prefix)
+	pager := s.client.NewListBlobsFlatPager(s.container, &azblob.ListBlobsFlatOptions{Prefix: &key})
+	for pager.More() {
+		page, err := pager.NextPage(s.ctx)
+		if err != nil {
+			return nil, fmt.Errorf("list %s: %w", prefix, err)
+		}
+		for _, item := range page.Segment.BlobItems {
+			names = append(names, s.name(*item.Name))
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// Stat returns the size and last modification time of the named blob.
+func (s *Store) Stat(name string) (fs.FileInfo, error) {
+	blobClient := s.client.ServiceClient().NewContainerClient(s.container).NewBlobClient(s.key(name))
+	props, err := blobClient.GetProperties(s.ctx, nil)
+	if bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return nil, fmt.Errorf("stat %s: %w (%w)", name, fs.ErrNotExist, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("stat %s: %w", name, err)
+	}
+	info := blobInfo{name: name}
+	if props.ContentLength != nil {
+		info.size = *props.ContentLength
+	}
+	if props.LastModified != nil {
+		info.modTime = *props.LastModified
+	}
+	return info, nil
+}
+
+// Delete removes the named blob and its snapshots. Deleting a missing blob
+// is not an error.
+func (s *Store) Delete(name string) error {
+	_, err := s.client.DeleteBlob(s.ctx, s.container, s.key(name), &azblob.DeleteBlobOptions{
+		DeleteSnapshots: to.Ptr(blob.DeleteSnapshotsOptionTypeInclude),
+	})
+	if err != nil && !bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return fmt.Errorf("delete %s: %w", name, err)
+	}
+	return nil
+}
+
+// blobWriter feeds an io.Pipe whose read end is consumed by UploadStream.
+type blobWriter struct {
+	pw   *io.PipeWriter
+	done chan error
+}
+
+func (w *blobWriter) Write(p []byte) (int, error) {
+	return w.pw.Write(p)
+}
+
+func (w *blobWriter) Close() error {
+	if err := w.pw.Close(); err != nil {
+		return err
+	}
+	return <-w.done
+}
+
+// blobInfo describes a blob as an fs.FileInfo.
+type blobInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (bi blobInfo) Name() string       { return bi.name }
+func (bi blobInfo) Size() int64        { return bi.size }
+func (bi blobInfo) Mode() fs.FileMode  { return 0o644 }
+func (bi blobInfo) ModTime() time.Time { return bi.modTime }
+func (bi blobInfo) IsDir() bool        { return false }
+func (bi blobInfo) Sys() any           { return nil }
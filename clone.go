@@ -0,0 +1,140 @@
+package pg_mini
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const cloneTblPrefix = "tmp_clone_"
+
+func cloneTblName(table string) string {
+	return fmt.Sprintf("%s%s", cloneTblPrefix, table)
+}
+
+// cloneKeyCol returns the surrogate key column of a table: its single primary
+// key column, unless that column is also a foreign key (in which case it is
+// rewritten through the referenced table's map instead).
+func cloneKeyCol(g *Graph, schema *Schema, tbl string) string {
+	pk := schema.Tables[tbl].PrimaryKeyCols
+	if len(pk) != 1 {
+		return ""
+	}
+	for _, rel := range g.Relations {
+		if rel.FromTable == tbl && rel.FromColumn == pk[0] {
+			return ""
+		}
+	}
+	return pk[0]
+}
+
+// cloneMapFor returns the map table holding new values for tbl.col, following
+// foreign keys that are themselves primary keys (1:1 extension tables).
+func cloneMapFor(g *Graph, schema *Schema, subtree []string, tbl, col string, depth int) string {
+	if depth > len(g.Tables) || !slices.Contains(subtree, tbl) {
+		return ""
+	}
+	if cloneKeyCol(g, schema, tbl) == col {
+		return cloneTblName(tbl)
+	}
+	for _, rel := range g.Relations {
+		if rel.FromTable == tbl && rel.FromColumn == col {
+			if m := cloneMapFor(g, schema, subtree, rel.ToTable, rel.ToColumn, depth+1); m != "" {
+				return m
+			}
+		}
+	}
+	return ""
+}
+
+type cloneOpts struct {
+	// Subtree lists the cloned tables, see dependentTables.
+	Subtree []string
+	// KeyExprs generates a new key per row (e.g.
+	// "nextval('company_id_seq'::regclass)"), keyed by backup table name.
+	KeyExprs map[string]string
+}
+
+// fillQueries sets the clone queries of a subtree table. cols and exprs are
+// the inserted target columns and the expressions filling them from the
+// staged CSV columns csvCols.
+//
+// The table is staged with CreateTemp/CopyTemp as usual. CloneKeys then
+// records an old→new map for its surrogate key, and CloneInsert inserts the
+// staged rows with the key and every foreign key into the subtree rewritten
+// through those maps. Maps are kept until CloneDrop so later tables can use
+// them.
+func (c *cloneOpts) fillQueries(g *Graph, schema *Schema, tq *ImportTableQueries, csvCols, cols, exprs []string) {
+	tmpName := "tmp_import_" + tq.Table
+
+	keyCol := cloneKeyCol(g, schema, tq.Table)
+	if keyCol != "" {
+		tq.CloneKeys = fmt.Sprintf(
+			"CREATE TEMP TABLE %s AS SELECT %s AS pg_mini_old, %s AS pg_mini_new FROM %s;",
+			cloneTblName(tq.Table), keyCol, c.KeyExprs[tq.Table], tmpName,
+		)
+		tq.CloneDrop = fmt.Sprintf("DROP TABLE IF EXISTS %s;", cloneTblName(tq.Table))
+	}
+
+	// Rewrite key and foreign key columns through the maps
+	exprs = slices.Clone(exprs)
+	var joins []string
+	for idx, expr := range exprs {
+		if !slices.Contains(csvCols, expr) {
+			continue // a mapping expression, left as is
+		}
+		mapTbl := cloneMapFor(g, schema, c.Subtree, tq.Table, expr, 0)
+		if mapTbl == "" {
+			continue
+		}
+		alias := fmt.Sprintf("m%d", len(joins))
+		joins = append(joins, fmt.Sprintf("LEFT JOIN %s AS %s ON %s.pg_mini_old = src.%s", mapTbl, alias, alias, expr))
+		if expr == keyCol {
+			exprs[idx] = alias + ".pg_mini_new"
+		} else {
+			exprs[idx] = fmt.Sprintf("COALESCE(%s.pg_mini_new, src.%s)", alias, expr)
+		}
+	}
+
+	from := tmpName + " AS src"
+	if len(joins) > 0 {
+		from += " " + strings.Join(joins, " ")
+	}
+	tq.CloneInsert = fmt.Sprintf(
+		"INSERT INTO %s (%s) OVERRIDING SYSTEM VALUE SELECT %s FROM %s;",
+		tq.Target, strings.Join(cols, ", "), strings.Join(exprs, ", "), from,
+	)
+}
+
+// maxKeyExpr numbers new keys of an integer column without a default after
+// the current maximum. Concurrent inserts into the table can take the same
+// keys.
+func maxKeyExpr(table, column string) string {
+	return fmt.Sprintf("(SELECT coalesce(max(%s), 0) FROM %s) + row_number() OVER ()",
+		pgx.Identifier{column}.Sanitize(), pgx.Identifier{table}.Sanitize())
+}
+
+// getKeyDefault returns the expression generating new values for a key
+// column: nextval on the backing sequence for identity/serial columns, or the
+// column default (e.g. gen_random_uuid()). Empty if the column has neither.
+func getKeyDefault(ctx context.Context, conn *pgx.Conn, table, column string) (string, error) {
+	query := `
+		SELECT
+			CASE WHEN c.is_identity = 'YES'
+				THEN format('nextval(%L::regclass)', pg_get_serial_sequence(format('%I.%I', c.table_schema, c.table_name), c.column_name))
+				ELSE coalesce(c.column_default, '')
+			END
+		FROM information_schema.columns c
+		WHERE c.table_schema = 'public' AND c.table_name = $1 AND c.column_name = $2
+	`
+
+	var expr string
+	err := conn.QueryRow(ctx, query, table, column).Scan(&expr)
+	if err != nil {
+		return "", fmt.Errorf("querying default for %s.%s: %w", table, column, err)
+	}
+	return expr, nil
+}
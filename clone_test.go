@@ -0,0 +1,91 @@
+package pg_mini
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func Test_generateImportQueries_Clone(t *testing.T) {
+	backup, _ := driftTestSchemas()
+	backup.Tables["tag"] = tableSchema{
+		Name:           "tag",
+		Cols:           []columnSchema{{Name: "id", Type: "int4"}},
+		PrimaryKeyCols: []string{"id"},
+	}
+	backup.Tables["invoice_tag"] = tableSchema{
+		Name: "invoice_tag",
+		Cols: []columnSchema{
+			{Name: "invoice_id", Type: "int4"},
+			{Name: "tag_id", Type: "int4"},
+		},
+		PrimaryKeyCols: []string{"invoice_id", "tag_id"},
+	}
+	backup.Relations = append(backup.Relations,
+		foreignKeyRelation{FromTable: "invoice_tag", FromColumn: "invoice_id", ToTable: "invoice", ToColumn: "id"},
+		foreignKeyRelation{FromTable: "invoice_tag", FromColumn: "tag_id", ToTable: "tag", ToColumn: "id"},
+	)
+
+	graph, err := buildGraph(backup, "account")
+	if err != nil {
+		t.Fatalf("buildGraph: %v", err)
+	}
+
+	subtree := dependentTables(graph)
+	if d := deep.Equal(subtree, []string{"account", "invoice", "invoice_tag"}); d != nil {
+		t.Fatalf("subtree: %v", d)
+	}
+
+	queries := generateImportQueries(graph, backup, importQueryOpts{
+		Mapping: Mapping{"account": {Set: map[string]string{"fullname": "fullname || ' (copy)'"}}},
+		Clone: &cloneOpts{
+			Subtree: subtree,
+			KeyExprs: map[string]string{
+				"account": "nextval('account_id_seq'::regclass)",
+				"invoice": "nextval('invoice_id_seq'::regclass)",
+			},
+		},
+	})
+
+	type cloneQueries struct {
+		Table, CloneKeys, CloneInsert, CloneDrop string
+	}
+	var got []cloneQueries
+	for _, tq := range queries {
+		got = append(got, cloneQueries{tq.Table, tq.CloneKeys, tq.CloneInsert, tq.CloneDrop})
+	}
+
+	want := []cloneQueries{
+		{
+			Table:       "account",
+			CloneKeys:   "CREATE TEMP TABLE tmp_clone_account AS SELECT id AS pg_mini_old, nextval('account_id_seq'::regclass) AS pg_mini_new FROM tmp_import_account;",
+			CloneInsert: "INSERT INTO account (id, fullname, score) OVERRIDING SYSTEM VALUE SELECT m0.pg_mini_new, fullname || ' (copy)', score FROM tmp_import_account AS src LEFT JOIN tmp_clone_account AS m0 ON m0.pg_mini_old = src.id;",
+			CloneDrop:   "DROP TABLE IF EXISTS tmp_clone_account;",
+		},
+		{
+			Table:       "invoice",
+			CloneKeys:   "CREATE TEMP TABLE tmp_clone_invoice AS SELECT id AS pg_mini_old, nextval('invoice_id_seq'::regclass) AS pg_mini_new FROM tmp_import_invoice;",
+			CloneInsert: "INSERT INTO invoice (id, account_id) OVERRIDING SYSTEM VALUE SELECT m0.pg_mini_new, COALESCE(m1.pg_mini_new, src.account_id) FROM tmp_import_invoice AS src LEFT JOIN tmp_clone_invoice AS m0 ON m0.pg_mini_old = src.id LEFT JOIN tmp_clone_account AS m1 ON m1.pg_mini_old = src.account_id;",
+			CloneDrop:   "DROP TABLE IF EXISTS tmp_clone_invoice;",
+		},
+		{
+			// Composite key of foreign keys: no map of its own, tag_id keeps
+			// pointing at the existing tag.
+			Table:       "invoice_tag",
+			CloneInsert: "INSERT INTO invoice_tag (invoice_id, tag_id) OVERRIDING SYSTEM VALUE SELECT COALESCE(m0.pg_mini_new, src.invoice_id), tag_id FROM tmp_import_invoice_tag AS src LEFT JOIN tmp_clone_invoice AS m0 ON m0.pg_mini_old = src.invoice_id;",
+		},
+	}
+	if d := deep.Equal(got, want); d != nil {
+		for _, line := range d {
+			t.Error(line)
+		}
+	}
+}
+
+func Test_maxKeyExpr(t *testing.T) {
+	got := maxKeyExpr("Order", "user")
+	want := `(SELECT coalesce(max("user"), 0) FROM "Order") + row_number() OVER ()`
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
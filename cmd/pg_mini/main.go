@@ -1,54 +1,364 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
+	"maps"
 	"net/url"
 	"os"
 	"os/signal"
+	"slices"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/fritzkeyzer/pg_mini"
+	"github.com/fritzkeyzer/pg_mini/azblob_store"
+	"github.com/fritzkeyzer/pg_mini/gcs_store"
 	"github.com/fritzkeyzer/pg_mini/s3_store"
 	"github.com/jackc/pgx/v5"
 	"github.com/lmittmann/tint"
 	"github.com/urfave/cli/v3"
 )
 
-// s3Flags apply when --out is an s3:// URL. Credentials come from the
-// AWS_ACCESS_KEY_ID / AWS_SECRET_ACCESS_KEY env vars or the IAM chain.
+// s3Flags apply when --out is an s3:// URL. Credentials come from
+// PG_MINI_S3_KEY_ID / PG_MINI_S3_ACCESS_KEY, or else the AWS chain: env vars,
+// AWS_PROFILE and the shared credentials file, web identity (EKS IRSA),
+// container and instance roles.
 var s3Flags = []cli.Flag{
 	&cli.StringFlag{Name: "s3-endpoint", Value: "s3.amazonaws.com", Usage: "S3 host, e.g. localhost:9000 for MinIO"},
 	&cli.StringFlag{Name: "s3-region", Usage: "S3 region (required by some endpoints)"},
 	&cli.BoolFlag{Name: "s3-insecure", Usage: "use plain HTTP instead of HTTPS (for local MinIO)"},
+	&cli.StringFlag{Name: "s3-profile", Usage: "shared credentials file profile (default: AWS_PROFILE, then the rest of the AWS credential chain)"},
+	&cli.StringFlag{Name: "s3-role-arn", Usage: "IAM role to assume with the resolved credentials"},
+	&cli.StringFlag{Name: "s3-external-id", Usage: "external id required by the trust policy of --s3-role-arn"},
+	&cli.StringFlag{Name: "s3-sse", Usage: "server-side encryption: s3, kms or customer (key in PG_MINI_S3_SSE_CUSTOMER_KEY, base64)"},
+	&cli.StringFlag{Name: "s3-sse-kms-key-id", Usage: "KMS key id or ARN for --s3-sse=kms (default: the AWS managed key)"},
+	&cli.StringFlag{Name: "s3-storage-class", Usage: "storage class of written objects, e.g. STANDARD_IA"},
+	&cli.StringSliceFlag{Name: "s3-metadata", Usage: "user metadata key=value set on written objects (repeatable)"},
+	&cli.StringSliceFlag{Name: "s3-tag", Usage: "object tag key=value set on written objects (repeatable); needs s3:PutObjectTagging"},
+	&cli.BoolFlag{Name: "s3-default-tags", Usage: "also tag written objects with root_table, pg_mini_version and env (if --s3-env is set)"},
+	&cli.StringFlag{Name: "s3-env", Usage: "environment for the env tag of --s3-default-tags, e.g. prod (default: PG_MINI_ENV)"},
+	&cli.IntFlag{Name: "s3-part-size", Usage: "multipart upload part size in MiB (default 16, minimum 5)"},
+	&cli.IntFlag{Name: "s3-concurrency", Usage: "parts uploaded and ranges read in parallel per file (default 4)"},
+	&cli.IntFlag{Name: "s3-max-attempts", Usage: "tries per S3 request before giving up, with exponential backoff (default 5)"},
 }
 
-// buildStore selects the S3 backend for an s3://bucket/prefix URL, or a local
-// directory otherwise.
-func buildStore(ctx context.Context, out string, cmd *cli.Command) (pg_mini.Store, error) {
-	if !strings.HasPrefix(out, "s3://") {
-		return pg_mini.DirStore(out), nil
-	}
-	u, err := url.Parse(out)
+// gcsFlags apply when --out is a gs:// URL. Credentials come from
+// Application Default Credentials (GOOGLE_APPLICATION_CREDENTIALS, workload
+// identity, gcloud auth).
+var gcsFlags = []cli.Flag{
+	&cli.StringFlag{Name: "gcs-endpoint", Usage: "GCS JSON API endpoint, e.g. http://localhost:4443/storage/v1/ for fake-gcs-server"},
+}
+
+// encryptionFlags encrypt the files of any backend. The key can also come
+// from PG_MINI_ENCRYPTION_KEY (base64) or PG_MINI_ENCRYPTION_PASSPHRASE.
+var encryptionFlags = []cli.Flag{
+	&cli.StringFlag{Name: "encryption-key-file", Usage: "encrypt files client-side with the 32 byte key in this file (raw or base64)"},
+}
+
+// storeFlags are the flags of every remote backend and of encryption.
+var storeFlags = slices.Concat(s3Flags, gcsFlags, encryptionFlags)
+
+// buildStore opens the backend for out, see backendStore, and wraps it in an
+// EncryptedStore if an encryption key is configured. The returned function
+// finishes the store and must be called once the run is done, with keep false
+// if the run failed or was not meant to write a backup, so that a new archive
+// is discarded. importing opens archives for reading instead of writing a new
+// one.
+func buildStore(ctx context.Context, out string, cmd *cli.Command, importing bool) (pg_mini.Store, func(keep bool) error, error) {
+	key, err := encryptionKey(cmd)
 	if err != nil {
-		return nil, fmt.Errorf("invalid --out s3 url: %w", err)
+		return nil, nil, err
+	}
+	store, closeStore, err := backendStore(ctx, out, cmd, importing)
+	if err != nil || key == nil {
+		return store, closeStore, err
+	}
+	return pg_mini.EncryptedStore(store, key), closeStore, nil
+}
+
+// encryptionKey returns the key of --encryption-key-file,
+// PG_MINI_ENCRYPTION_KEY or PG_MINI_ENCRYPTION_PASSPHRASE, nil if none is
+// set.
+func encryptionKey(cmd *cli.Command) (*pg_mini.EncryptionKey, error) {
+	path := cmd.String("encryption-key-file")
+	key := os.Getenv("PG_MINI_ENCRYPTION_KEY")
+	passphrase := os.Getenv("PG_MINI_ENCRYPTION_PASSPHRASE")
+	if set := len(slices.DeleteFunc([]string{path, key, passphrase}, func(v string) bool { return v == "" })); set > 1 {
+		return nil, fmt.Errorf("set only one of --encryption-key-file, PG_MINI_ENCRYPTION_KEY and PG_MINI_ENCRYPTION_PASSPHRASE")
 	}
-	bucket := u.Host
-	if bucket == "" {
-		return nil, fmt.Errorf("s3 url must include a bucket: s3://bucket/prefix")
+
+	switch {
+	case path != "":
+		return pg_mini.LoadEncryptionKey(path)
+	case key != "":
+		k, err := pg_mini.ParseEncryptionKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PG_MINI_ENCRYPTION_KEY: %w", err)
+		}
+		return k, nil
+	case passphrase != "":
+		return pg_mini.PassphraseKey(passphrase)
 	}
-	return s3_store.New(ctx, s3_store.Config{
+	return nil, nil
+}
+
+// backendStore selects the S3 backend for an s3://bucket/prefix URL, GCS for
+// gs://bucket/prefix, Azure Blob Storage for azblob://container/prefix or
+// https://<account>.blob.core.windows.net/container/prefix, a single archive
+// for a .tar, .tar.zst or .zip path, or a local directory otherwise.
+//
+// Archives are never modified: an export writes a new one, and an import
+// reads it and writes its reports and rejected rows to the directory
+// archiveArtifactsDir names.
+func backendStore(ctx context.Context, out string, cmd *cli.Command, importing bool) (pg_mini.Store, func(keep bool) error, error) {
+	noop := func(bool) error { return nil }
+	switch {
+	case strings.HasPrefix(out, "s3://"):
+		bucket, prefix, err := parseBucketURL(out)
+		if err != nil {
+			return nil, nil, err
+		}
+		cfg, err := s3Config(cmd)
+		if err != nil {
+			return nil, nil, err
+		}
+		cfg.Bucket, cfg.Prefix = bucket, prefix
+		store, err := s3_store.New(ctx, cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		return store, noop, nil
+
+	case strings.HasPrefix(out, "gs://"):
+		bucket, prefix, err := parseBucketURL(out)
+		if err != nil {
+			return nil, nil, err
+		}
+		store, err := gcs_store.New(ctx, gcs_store.Config{
+			Bucket:   bucket,
+			Prefix:   prefix,
+			Endpoint: cmd.String("gcs-endpoint"),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return store, func(bool) error { return store.Close() }, nil
+
+	case isAzblobURL(out):
+		cfg, err := azblobConfig(out)
+		if err != nil {
+			return nil, nil, err
+		}
+		store, err := azblob_store.New(ctx, cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		return store, noop, nil
+
+	case pg_mini.IsArchivePath(out) && importing:
+		archive, err := pg_mini.OpenArchive(out)
+		if err != nil {
+			return nil, nil, err
+		}
+		return pg_mini.OverlayStore(archive, pg_mini.DirStore(archiveArtifactsDir(out))), func(bool) error { return archive.Close() }, nil
+
+	case pg_mini.IsArchivePath(out):
+		archive, err := pg_mini.CreateArchive(out)
+		if err != nil {
+			return nil, nil, err
+		}
+		finish := func(keep bool) error {
+			if !keep {
+				return archive.Discard()
+			}
+			return archive.Close()
+		}
+		return archive, finish, nil
+	}
+	return pg_mini.DirStore(out), noop, nil
+}
+
+// archiveArtifactsDir is where an import from an archive writes its files:
+// products.tar.zst gets products.import next to it.
+func archiveArtifactsDir(archive string) string {
+	for _, kind := range []string{".tar.zst", ".tar", ".zip"} {
+		if base, ok := strings.CutSuffix(archive, kind); ok {
+			return base + ".import"
+		}
+	}
+	return archive + ".import"
+}
+
+// s3Config builds the S3 config from the --s3-* flags and env vars. Objects
+// are only tagged when asked to, since tagging needs an extra permission and
+// is not supported by every S3-compatible backend: --s3-default-tags adds
+// root_table, pg_mini_version and env (from --s3-env or PG_MINI_ENV), --s3-tag
+// adds to or overrides them.
+func s3Config(cmd *cli.Command) (s3_store.Config, error) {
+	cfg := s3_store.Config{
 		Endpoint:        cmd.String("s3-endpoint"),
-		Bucket:          bucket,
-		Prefix:          strings.Trim(u.Path, "/"),
 		Region:          cmd.String("s3-region"),
 		UseSSL:          !cmd.Bool("s3-insecure"),
 		AccessKeyID:     os.Getenv("PG_MINI_S3_KEY_ID"),
 		SecretAccessKey: os.Getenv("PG_MINI_S3_ACCESS_KEY"),
-	})
+		SessionToken:    os.Getenv("PG_MINI_S3_SESSION_TOKEN"),
+		Profile:         cmd.String("s3-profile"),
+		RoleARN:         cmd.String("s3-role-arn"),
+		ExternalID:      cmd.String("s3-external-id"),
+		SSE:             cmd.String("s3-sse"),
+		KMSKeyID:        cmd.String("s3-sse-kms-key-id"),
+		StorageClass:    cmd.String("s3-storage-class"),
+		PartSize:        int64(cmd.Int("s3-part-size")) << 20,
+		Retry:           s3_store.RetryPolicy{MaxAttempts: cmd.Int("s3-max-attempts")},
+	}
+	if n := cmd.Int("s3-concurrency"); n != 0 {
+		cfg.UploadConcurrency, cfg.ReadConcurrency = n, n
+	}
+
+	if key := os.Getenv("PG_MINI_S3_SSE_CUSTOMER_KEY"); key != "" {
+		decoded, err := base64.StdEncoding.DecodeString(key)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid PG_MINI_S3_SSE_CUSTOMER_KEY: %w", err)
+		}
+		cfg.CustomerKey = decoded
+	}
+
+	metadata, err := parseKeyValues("s3-metadata", cmd.StringSlice("s3-metadata"))
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Metadata = metadata
+
+	tags, err := parseKeyValues("s3-tag", cmd.StringSlice("s3-tag"))
+	if err != nil {
+		return cfg, err
+	}
+	if cmd.Bool("s3-default-tags") {
+		cfg.Tags = map[string]string{"pg_mini_version": pg_mini.Version}
+		if tbl := cmd.String("table"); tbl != "" {
+			cfg.Tags["root_table"] = tbl
+		}
+		if env := cmp.Or(cmd.String("s3-env"), os.Getenv("PG_MINI_ENV")); env != "" {
+			cfg.Tags["env"] = env
+		}
+		maps.Copy(cfg.Tags, tags)
+	} else {
+		cfg.Tags = tags
+	}
+	return cfg, nil
+}
+
+// parseKeyValues parses repeated key=value flag values.
+func parseKeyValues(flag string, values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	m := map[string]string{}
+	for _, v := range values {
+		key, value, ok := strings.Cut(v, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --%s %q: must be key=value", flag, v)
+		}
+		m[key] = value
+	}
+	return m, nil
+}
+
+// parseBucketURL splits a scheme://bucket/prefix URL.
+func parseBucketURL(out string) (bucket, prefix string, err error) {
+	u, err := url.Parse(out)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid --out url: %w", err)
+	}
+	if u.Host == "" {
+		return "", "", fmt.Errorf("%s url must include a bucket: %s://bucket/prefix", u.Scheme, u.Scheme)
+	}
+	return u.Host, strings.Trim(u.Path, "/"), nil
+}
+
+// isAzblobURL reports whether out addresses Azure Blob Storage.
+func isAzblobURL(out string) bool {
+	if strings.HasPrefix(out, "azblob://") {
+		return true
+	}
+	u, err := url.Parse(out)
+	return err == nil && u.Scheme == "https" && strings.HasSuffix(u.Host, ".blob.core.windows.net")
+}
+
+// azblobConfig builds the Azure Blob Storage config for an azblob:// or
+// account URL. Credentials come from AZURE_STORAGE_CONNECTION_STRING, or
+// AZURE_STORAGE_ACCOUNT / AZURE_STORAGE_KEY, falling back to the default
+// Azure credential chain.
+func azblobConfig(out string) (azblob_store.Config, error) {
+	cfg := azblob_store.Config{
+		ConnectionString: os.Getenv("AZURE_STORAGE_CONNECTION_STRING"),
+		AccountName:      os.Getenv("AZURE_STORAGE_ACCOUNT"),
+		AccountKey:       os.Getenv("AZURE_STORAGE_KEY"),
+	}
+	if strings.HasPrefix(out, "azblob://") {
+		container, prefix, err := parseBucketURL(out)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.Container, cfg.Prefix = container, prefix
+		return cfg, nil
+	}
+
+	u, err := url.Parse(out)
+	if err != nil {
+		return cfg, fmt.Errorf("invalid --out url: %w", err)
+	}
+	container, prefix, _ := strings.Cut(strings.Trim(u.Path, "/"), "/")
+	if container == "" {
+		return cfg, fmt.Errorf("azure url must include a container: https://<account>.blob.core.windows.net/container/prefix")
+	}
+	cfg.ServiceURL = fmt.Sprintf("https://%s/", u.Host)
+	cfg.AccountName, _, _ = strings.Cut(u.Host, ".")
+	cfg.Container, cfg.Prefix = container, prefix
+	return cfg, nil
+}
+
+// reportFlag prints the run report once the command finishes.
+var reportFlag = &cli.StringFlag{Name: "report", Usage: "print the run report to stdout: table or json"}
+
+// eventsFlag streams progress events as JSON lines.
+var eventsFlag = &cli.StringFlag{Name: "events", Usage: "write progress events as JSON lines to this file (- for stderr)"}
+
+// openEvents returns the observer for --events and a function closing its
+// file.
+func openEvents(path string) (pg_mini.Observer, func() error, error) {
+	switch path {
+	case "":
+		return nil, func() error { return nil }, nil
+	case "-":
+		return pg_mini.JSONLinesObserver(os.Stderr), func() error { return nil }, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create events file: %w", err)
+	}
+	return pg_mini.JSONLinesObserver(f), f.Close, nil
+}
+
+// printReport writes a run report to stdout in the --report format.
+func printReport(format string, report interface{ WriteTable(io.Writer) error }) error {
+	switch format {
+	case "table":
+		fmt.Println()
+		return report.WriteTable(os.Stdout)
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	return fmt.Errorf("invalid --report %q: must be table or json", format)
 }
 
 func main() {
@@ -85,12 +395,16 @@ func main() {
 					&cli.StringFlag{Name: "table", Usage: "required, the top-level table you want to base this export on"},
 					&cli.StringFlag{Name: "filter", Usage: "optional where clause (raw sql)"},
 					&cli.StringFlag{Name: "raw", Usage: "use the raw query instead of the filter"},
-					&cli.StringFlag{Name: "out", Usage: "required, where to write the exported files: a directory or an s3://bucket/prefix URL"},
+					&cli.StringFlag{Name: "format", Value: "csv", Usage: "file format of the exported tables: csv, binary (COPY binary, types must match on import), parquet or jsonl"},
+					&cli.StringFlag{Name: "sql-script", Usage: "also write data.sql, restorable without pg_mini: copy (COPY blocks, for psql) or insert (INSERT statements)"},
+					&cli.StringFlag{Name: "out", Usage: "required, where to write the exported files: a directory, a .tar, .tar.zst or .zip archive, or an s3://, gs:// or azblob://bucket/prefix URL"},
 					&cli.BoolFlag{Name: "dry", Usage: "skip execution of queries"},
 					&cli.BoolFlag{Name: "graph-only", Usage: "skip execution, only write graph.json"},
 					&verboseFlag,
 					&cli.BoolFlag{Name: "no-animations", Usage: "disables animations"},
-				}, s3Flags...),
+					reportFlag,
+					eventsFlag,
+				}, storeFlags...),
 				Action: func(ctx context.Context, cmd *cli.Command) error {
 					if cmd.Bool("verbose") {
 						logLevel.Set(slog.LevelDebug)
@@ -122,24 +436,45 @@ func main() {
 						return fmt.Errorf("connecting to database: %w", err)
 					}
 
-					store, err := buildStore(ctx, outDir, cmd)
+					store, closeStore, err := buildStore(ctx, outDir, cmd, false)
+					if err != nil {
+						return err
+					}
+					defer closeStore(false)
+
+					observer, closeEvents, err := openEvents(cmd.String("events"))
 					if err != nil {
 						return err
 					}
+					defer closeEvents()
 
 					export := &pg_mini.Export{
 						DB:           db,
 						RootTable:    rootTable,
 						Filter:       filter,
 						RawQuery:     rawQuery,
+						Format:       pg_mini.Format(cmd.String("format")),
+						SQLScript:    pg_mini.ScriptMode(cmd.String("sql-script")),
 						Store:        store,
+						Observer:     observer,
 						DryRun:       dryRun,
 						GraphOnly:    cmd.Bool("graph-only"),
 						Verbose:      cmd.Bool("verbose"),
 						NoAnimations: noAnimations,
 					}
 
-					return export.Run(ctx)
+					// Only a complete export replaces an existing archive
+					report, err := export.RunWithReport(ctx)
+					keep := err == nil && !export.DryRun && !export.GraphOnly
+					if cerr := closeStore(keep); cerr != nil && err == nil {
+						err = fmt.Errorf("closing store: %w", cerr)
+					}
+					if report != nil && cmd.String("report") != "" {
+						if perr := printReport(cmd.String("report"), report); perr != nil && err == nil {
+							err = perr
+						}
+					}
+					return err
 				},
 			},
 			{
@@ -150,14 +485,24 @@ func main() {
 					&cli.BoolFlag{Name: "truncate", Usage: "truncate the target table before importing"},
 					&cli.BoolFlag{Name: "upsert", Usage: "use INSERT ... ON CONFLICT DO UPDATE instead of plain COPY (requires primary keys)"},
 					&cli.BoolFlag{Name: "soft-insert", Usage: "use INSERT ... ON CONFLICT DO NOTHING instead of plain COPY (requires primary keys)"},
+					&cli.BoolFlag{Name: "sync", Usage: "upsert the backup, then delete rows of the exported subset that are missing from it"},
 					&cli.BoolFlag{Name: "skip-errors", Usage: "import rows one-by-one, log row errors, and continue"},
+					&cli.BoolFlag{Name: "retry-rejected", Usage: "re-import only the rows a previous --skip-errors run wrote to <table>.rejected.csv (implies --skip-errors)"},
 					&cli.IntFlag{Name: "max-errors", Value: -1, Usage: "maximum row errors before aborting (-1 means no limit)"},
-					&cli.StringFlag{Name: "out", Usage: "required, where to read the exported files from: a directory or an s3://bucket/prefix URL"},
+					&cli.IntFlag{Name: "batch-size", Value: pg_mini.DefaultBatchSize, Usage: "rows per round trip with --skip-errors; failing batches are split to isolate bad rows (1 means row by row)"},
+					&cli.StringFlag{Name: "triggers", Usage: "turn target triggers off during the import: replica (session_replication_role, also skips FK checks) or disable (ALTER TABLE ... DISABLE TRIGGER)"},
+					&cli.StringFlag{Name: "schema-drift", Usage: "compare the backup schema against the target first: fail, warn or adapt"},
+					&cli.StringFlag{Name: "upsert-policy", Usage: "JSON file with per-table conflict target and update columns for --upsert / --soft-insert"},
+					&cli.StringFlag{Name: "mapping", Usage: "JSON file mapping backup tables/columns onto the target schema"},
+					&cli.BoolFlag{Name: "clone", Usage: "import a copy of the root subtree with new keys (e.g. duplicate a customer in the same database)"},
+					&cli.StringFlag{Name: "out", Usage: "required, where to read the exported files from: a directory, a .tar, .tar.zst or .zip archive, or an s3://, gs:// or azblob://bucket/prefix URL"},
 					&cli.BoolFlag{Name: "dry", Usage: "skip execution of queries"},
 					&cli.BoolFlag{Name: "graph-only", Usage: "skip execution, only write graph.json"},
 					&verboseFlag,
 					&cli.BoolFlag{Name: "no-animations", Usage: "disables animations"},
-				}, s3Flags...),
+					reportFlag,
+					eventsFlag,
+				}, storeFlags...),
 				Action: func(ctx context.Context, cmd *cli.Command) error {
 					if cmd.Bool("verbose") {
 						logLevel.Set(slog.LevelDebug)
@@ -169,7 +514,8 @@ func main() {
 					truncate := cmd.Bool("truncate")
 					upsert := cmd.Bool("upsert")
 					softInsert := cmd.Bool("soft-insert")
-					skipErrors := cmd.Bool("skip-errors")
+					retryRejected := cmd.Bool("retry-rejected")
+					skipErrors := cmd.Bool("skip-errors") || retryRejected
 					maxErrors := cmd.Int("max-errors")
 
 					if connURI == "" {
@@ -188,32 +534,75 @@ func main() {
 						return fmt.Errorf("--upsert and --soft-insert are mutually exclusive")
 					}
 
+					var mapping pg_mini.Mapping
+					if path := cmd.String("mapping"); path != "" {
+						m, err := pg_mini.LoadMapping(path)
+						if err != nil {
+							return err
+						}
+						mapping = m
+					}
+
+					var upsertPolicies pg_mini.UpsertPolicies
+					if path := cmd.String("upsert-policy"); path != "" {
+						p, err := pg_mini.LoadUpsertPolicies(path)
+						if err != nil {
+							return err
+						}
+						upsertPolicies = p
+					}
+
 					db, err := pgx.Connect(ctx, connURI)
 					if err != nil {
 						return fmt.Errorf("connecting to database: %w", err)
 					}
 
-					store, err := buildStore(ctx, outDir, cmd)
+					store, closeStore, err := buildStore(ctx, outDir, cmd, true)
+					if err != nil {
+						return err
+					}
+					defer closeStore(false)
+
+					observer, closeEvents, err := openEvents(cmd.String("events"))
 					if err != nil {
 						return err
 					}
+					defer closeEvents()
 
 					importCmd := &pg_mini.Import{
-						DB:           db,
-						RootTable:    cmd.String("table"),
-						Truncate:     truncate,
-						Upsert:       upsert,
-						SoftInsert:   softInsert,
-						SkipErrors:   skipErrors,
-						MaxErrors:    maxErrors,
-						Store:        store,
-						DryRun:       cmd.Bool("dry"),
-						GraphOnly:    cmd.Bool("graph-only"),
-						Verbose:      cmd.Bool("verbose"),
-						NoAnimations: cmd.Bool("no-animations"),
+						DB:             db,
+						RootTable:      cmd.String("table"),
+						Truncate:       truncate,
+						Upsert:         upsert,
+						SoftInsert:     softInsert,
+						SkipErrors:     skipErrors,
+						MaxErrors:      maxErrors,
+						BatchSize:      cmd.Int("batch-size"),
+						RetryRejected:  retryRejected,
+						SchemaDrift:    pg_mini.DriftPolicy(cmd.String("schema-drift")),
+						UpsertPolicies: upsertPolicies,
+						Mapping:        mapping,
+						Clone:          cmd.Bool("clone"),
+						Sync:           cmd.Bool("sync"),
+						Triggers:       pg_mini.TriggerPolicy(cmd.String("triggers")),
+						Store:          store,
+						Observer:       observer,
+						DryRun:         cmd.Bool("dry"),
+						GraphOnly:      cmd.Bool("graph-only"),
+						Verbose:        cmd.Bool("verbose"),
+						NoAnimations:   cmd.Bool("no-animations"),
 					}
 
-					return importCmd.Run(ctx)
+					report, err := importCmd.RunWithReport(ctx)
+					if cerr := closeStore(err == nil); cerr != nil && err == nil {
+						err = fmt.Errorf("closing store: %w", cerr)
+					}
+					if report != nil && cmd.String("report") != "" {
+						if perr := printReport(cmd.String("report"), report); perr != nil && err == nil {
+							err = perr
+						}
+					}
+					return err
 				},
 			},
 		},
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"testing"
+
+	"github.com/fritzkeyzer/pg_mini"
+	"github.com/urfave/cli/v3"
+)
+
+func Test_s3Config_Tags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  string
+		want map[string]string
+	}{
+		{name: "untagged", args: nil},
+		{name: "explicit only", args: []string{"--s3-tag", "team=data"}, want: map[string]string{"team": "data"}},
+		{
+			name: "defaults",
+			args: []string{"--s3-default-tags", "--s3-env", "prod"},
+			want: map[string]string{"pg_mini_version": pg_mini.Version, "root_table": "company", "env": "prod"},
+		},
+		{
+			name: "env from PG_MINI_ENV",
+			args: []string{"--s3-default-tags"},
+			env:  "staging",
+			want: map[string]string{"pg_mini_version": pg_mini.Version, "root_table": "company", "env": "staging"},
+		},
+		{
+			name: "no env",
+			args: []string{"--s3-default-tags"},
+			want: map[string]string{"pg_mini_version": pg_mini.Version, "root_table": "company"},
+		},
+		{
+			name: "explicit overrides defaults",
+			args: []string{"--s3-default-tags", "--s3-env", "prod", "--s3-tag", "env=dev"},
+			want: map[string]string{"pg_mini_version": pg_mini.Version, "root_table": "company", "env": "dev"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PG_MINI_ENV", tt.env)
+
+			var got map[string]string
+			cmd := &cli.Command{
+				Flags: append([]cli.Flag{&cli.StringFlag{Name: "table"}}, s3Flags...),
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					cfg, err := s3Config(cmd)
+					got = cfg.Tags
+					return err
+				},
+			}
+			args := append([]string{"pg_mini", "--table", "company"}, tt.args...)
+			if err := cmd.Run(context.Background(), args); err != nil {
+				t.Fatal(err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
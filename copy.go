@@ -16,8 +16,8 @@ type copyOutRes struct {
 	FileSize int64
 }
 
-func copyToCSV(ctx context.Context, conn *pgx.Conn, store Store, tbl, query string) (*copyOutRes, error) {
-	name := tbl + ".csv"
+// copyOut runs a COPY ... TO STDOUT query, writing its output to name.
+func copyOut(ctx context.Context, conn *pgx.Conn, store Store, name, query string, progress func(int64)) (*copyOutRes, error) {
 	w, err := store.Create(name)
 	if err != nil {
 		return nil, fmt.Errorf("creating file: %w", err)
@@ -29,7 +29,7 @@ func copyToCSV(ctx context.Context, conn *pgx.Conn, store Store, tbl, query stri
 		}
 	}()
 
-	cw := &countingWriter{w: w}
+	cw := &countingWriter{w: w, progress: progress}
 	bufWriter := bufio.NewWriterSize(cw, 1024*1024)
 
 	queryStart := time.Now()
@@ -65,8 +65,10 @@ type copyInRes struct {
 	FileSize int64
 }
 
-func copyFromCSV(ctx context.Context, conn *pgx.Conn, store Store, tbl, query string) (*copyInRes, error) {
-	name := tbl + ".csv"
+// copyIn feeds the file of tq to a COPY ... FROM STDIN query. Parquet and
+// JSONL files are rendered as CSV.
+func copyIn(ctx context.Context, conn *pgx.Conn, store Store, tq ImportTableQueries, query string, progress func(int64)) (*copyInRes, error) {
+	name := tq.File
 	r, err := store.Open(name)
 	if err != nil {
 		return nil, fmt.Errorf("opening file: %w", err)
@@ -78,10 +80,15 @@ func copyFromCSV(ctx context.Context, conn *pgx.Conn, store Store, tbl, query st
 		}
 	}()
 
-	cr := &countingReader{r: r}
+	cr := &countingReader{r: r, progress: progress}
+	src, err := csvSource(cr, tq.Format, tq.fileCols)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
 
 	queryStart := time.Now()
-	copyCount, err := conn.PgConn().CopyFrom(ctx, cr, query)
+	copyCount, err := conn.PgConn().CopyFrom(ctx, src, query)
 	if err != nil {
 		return nil, fmt.Errorf("copying data: %w", err)
 	}
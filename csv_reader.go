@@ -0,0 +1,147 @@
+package pg_mini
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// csvReader reads CSV as written by COPY ... (FORMAT csv). Unlike
+// encoding/csv it tells NULL, an unquoted empty field, apart from an empty
+// string, which COPY always quotes ("").
+type csvReader struct {
+	r      *bufio.Reader
+	fields int // fields per record, set by the first record
+	line   int // line of the record last read
+	next   int // line the next record starts on
+}
+
+func newCSVReader(r io.Reader) *csvReader {
+	return &csvReader{r: bufio.NewReaderSize(r, 1024*1024), next: 1}
+}
+
+var errCSVFieldCount = errors.New("wrong number of fields")
+
+// Read returns the next record and which of its fields are NULL. It returns
+// io.EOF when there are no more records. A record with the wrong number of
+// fields is returned along with an error; for malformed quoting the rest of
+// the record is skipped and only the error is returned.
+func (c *csvReader) Read() (record []string, nulls []bool, err error) {
+	c.line = c.next
+
+	var field strings.Builder
+	quoted, inQuotes, started := false, false, false
+
+	endField := func() {
+		record = append(record, field.String())
+		nulls = append(nulls, !quoted && field.Len() == 0)
+		field.Reset()
+		quoted = false
+	}
+
+	for {
+		b, err := c.r.ReadByte()
+		if errors.Is(err, io.EOF) {
+			if inQuotes {
+				return nil, nil, fmt.Errorf("record on line %d: extraneous or missing \" in quoted-field", c.line)
+			}
+			if !started {
+				return nil, nil, io.EOF
+			}
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		started = true
+
+		if inQuotes {
+			if b == '"' {
+				if next, err := c.r.Peek(1); err == nil && next[0] == '"' {
+					c.r.ReadByte()
+					field.WriteByte('"')
+					continue
+				}
+				inQuotes = false
+				continue
+			}
+			if b == '\n' {
+				c.next++
+			}
+			field.WriteByte(b)
+			continue
+		}
+
+		if b == '\n' {
+			break
+		}
+		if b == '\r' {
+			if next, err := c.r.Peek(1); err == nil && next[0] == '\n' {
+				continue
+			}
+		}
+		if b == ',' {
+			endField()
+			continue
+		}
+		if b == '"' && field.Len() == 0 && !quoted {
+			quoted, inQuotes = true, true
+			continue
+		}
+		if b == '"' || quoted {
+			c.skipRecord()
+			return nil, nil, fmt.Errorf("record on line %d: bare \" in non-quoted-field", c.line)
+		}
+		field.WriteByte(b)
+	}
+	endField()
+	c.next++
+
+	if c.fields == 0 {
+		c.fields = len(record)
+	} else if len(record) != c.fields {
+		return record, nulls, fmt.Errorf("record on line %d: %w", c.line, errCSVFieldCount)
+	}
+	return record, nulls, nil
+}
+
+// skipRecord discards the rest of a malformed record, up to the next newline
+// outside quotes.
+func (c *csvReader) skipRecord() {
+	inQuotes := false
+	for {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return
+		}
+		switch {
+		case b == '"':
+			inQuotes = !inQuotes
+		case b == '\n':
+			c.next++
+			if !inQuotes {
+				return
+			}
+		}
+	}
+}
+
+// writeCSVRecord writes a record the way COPY ... (FORMAT csv, FORCE_QUOTE *)
+// does: NULL fields empty and unquoted, every other value quoted.
+func writeCSVRecord(w *bufio.Writer, record []string, nulls []bool) error {
+	for idx, val := range record {
+		if idx > 0 {
+			w.WriteByte(',')
+		}
+		if idx < len(nulls) && nulls[idx] {
+			continue
+		}
+		w.WriteByte('"')
+		w.WriteString(strings.ReplaceAll(val, `"`, `""`))
+		w.WriteByte('"')
+	}
+	_, err := w.WriteString("\n")
+	return err
+}
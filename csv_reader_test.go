@@ -0,0 +1,83 @@
+package pg_mini
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestCSVReader(t *testing.T) {
+	input := "id,name,note\r\n" +
+		`"1","",` + "\n" +
+		`"2","say ""hi""","multi` + "\n" + `line"` + "\n" +
+		`"3",x"y,z` + "\n" +
+		`"4","a"` + "\n" +
+		`"5",,"ok"` + "\n"
+	r := newCSVReader(strings.NewReader(input))
+
+	type row struct {
+		record []string
+		nulls  []bool
+		line   int
+		err    bool
+	}
+	want := []row{
+		{record: []string{"id", "name", "note"}, nulls: []bool{false, false, false}, line: 1},
+		{record: []string{"1", "", ""}, nulls: []bool{false, false, true}, line: 2},
+		{record: []string{"2", `say "hi"`, "multi\nline"}, nulls: []bool{false, false, false}, line: 3},
+		{err: true, line: 5},
+		{record: []string{"4", "a"}, nulls: []bool{false, false}, line: 6, err: true},
+		{record: []string{"5", "", "ok"}, nulls: []bool{false, true, false}, line: 7},
+	}
+	for _, w := range want {
+		record, nulls, err := r.Read()
+		if (err != nil) != w.err {
+			t.Fatalf("line %d: unexpected error %v", w.line, err)
+		}
+		if !reflect.DeepEqual(record, w.record) || !reflect.DeepEqual(nulls, w.nulls) || r.line != w.line {
+			t.Errorf("line %d: got %q %v (line %d)", w.line, record, nulls, r.line)
+		}
+	}
+	if _, _, err := r.Read(); !errors.Is(err, io.EOF) {
+		t.Errorf("want EOF, got %v", err)
+	}
+}
+
+func TestWriteCSVRecord(t *testing.T) {
+	var sb strings.Builder
+	w := bufio.NewWriter(&sb)
+	if err := writeCSVRecord(w, []string{"a", "", "", `q"`}, []bool{false, false, true, false}); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+	if got, want := sb.String(), `"a","",,"q"""`+"\n"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	record, nulls, err := newCSVReader(strings.NewReader(sb.String())).Read()
+	if err != nil || !reflect.DeepEqual(record, []string{"a", "", "", `q"`}) || !reflect.DeepEqual(nulls, []bool{false, false, true, false}) {
+		t.Errorf("round trip: %q %v %v", record, nulls, err)
+	}
+}
+
+func TestRowDecoder(t *testing.T) {
+	decode := newRowDecoder(pgtype.NewMap(), []string{"int4", "bytea", "_text", ""})
+
+	if v, err := decode(0, "42"); err != nil || v != int32(42) {
+		t.Errorf("int4: got %#v, %v", v, err)
+	}
+	if v, err := decode(1, `\x6869`); err != nil || string(v.([]byte)) != "hi" {
+		t.Errorf("bytea: got %#v, %v", v, err)
+	}
+	if v, err := decode(2, "{a,b}"); err != nil || v != "{a,b}" {
+		t.Errorf("arrays stay text: got %#v, %v", v, err)
+	}
+	if _, err := decode(0, "abc"); err == nil {
+		t.Errorf("int4: want error for invalid input")
+	}
+}
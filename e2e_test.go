@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"slices"
 	"sort"
 	"strings"
 	"testing"
@@ -535,3 +536,518 @@ func TestE2E_SoftInsert(t *testing.T) {
 		t.Errorf("table company: want 4 rows, got %d", len(restored["company"]))
 	}
 }
+
+func TestE2E_Clone(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test in short mode")
+	}
+
+	connStr := startPostgres(t)
+	ctx := context.Background()
+
+	setupConn := connect(t, connStr)
+	execSQLFile(t, setupConn, "testdata/e2e/company/setup.sql")
+	original := snapshotDB(t, setupConn)
+
+	store := NewMemStore()
+	exp := &Export{
+		DB:           connect(t, connStr),
+		RootTable:    "company",
+		Filter:       "WHERE id = 1",
+		Store:        store,
+		NoAnimations: true,
+	}
+	if err := exp.Run(ctx); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	// Clone company 1 into the same database
+	imp := &Import{
+		DB:        connect(t, connStr),
+		RootTable: "company",
+		Clone:     true,
+		Mapping: Mapping{
+			"company": {Set: map[string]string{"name": "name || ' (copy)'"}},
+		},
+		Store:        store,
+		NoAnimations: true,
+	}
+	if err := imp.Run(ctx); err != nil {
+		t.Fatalf("import clone: %v", err)
+	}
+
+	verifyConn := connect(t, connStr)
+
+	// company has no key default, so the clone is numbered after the maximum
+	var name string
+	if err := verifyConn.QueryRow(ctx, "SELECT name FROM company WHERE id = 4").Scan(&name); err != nil {
+		t.Fatalf("verify cloned company: %v", err)
+	}
+	if name != "Acme Corp (copy)" {
+		t.Errorf("expected cloned name 'Acme Corp (copy)', got %s", name)
+	}
+
+	for query, want := range map[string]int{
+		"SELECT count(*) FROM website WHERE company_id = 4":                                                 2,
+		"SELECT count(*) FROM company_tag WHERE company_id = 4":                                             2,
+		"SELECT count(*) FROM website_tag wt JOIN website w ON w.id = wt.website_id WHERE w.company_id = 4": 2,
+		"SELECT count(*) FROM tag":                                                                          len(original["tag"]),
+	} {
+		var got int
+		if err := verifyConn.QueryRow(ctx, query).Scan(&got); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		if got != want {
+			t.Errorf("%s: want %d, got %d", query, want, got)
+		}
+	}
+
+	// The original rows are untouched
+	restored := snapshotDB(t, verifyConn)
+	if len(restored["company"]) != len(original["company"])+1 {
+		t.Errorf("table company: want %d rows, got %d", len(original["company"])+1, len(restored["company"]))
+	}
+}
+
+func TestE2E_Sync(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test in short mode")
+	}
+
+	connStr := startPostgres(t)
+	ctx := context.Background()
+
+	setupConn := connect(t, connStr)
+	execSQLFile(t, setupConn, "testdata/e2e/company/setup.sql")
+
+	store := NewMemStore()
+	exp := &Export{
+		DB:           connect(t, connStr),
+		RootTable:    "company",
+		Filter:       "WHERE id = 1",
+		Store:        store,
+		NoAnimations: true,
+	}
+	if err := exp.Run(ctx); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	mutateConn := connect(t, connStr)
+	for _, q := range []string{
+		"UPDATE website SET url = 'MUTATED' WHERE id = 1",                                       // in subset: restored
+		"INSERT INTO website (id, company_id, url) VALUES (99, 1, 'https://new.example.com')",   // in subset, not in backup: deleted
+		"INSERT INTO website (id, company_id, url) VALUES (98, 2, 'https://other.example.com')", // outside subset: kept
+	} {
+		if _, err := mutateConn.Exec(ctx, q); err != nil {
+			t.Fatalf("mutate %s: %v", q, err)
+		}
+	}
+
+	imp := &Import{
+		DB:           connect(t, connStr),
+		RootTable:    "company",
+		Sync:         true,
+		Store:        store,
+		NoAnimations: true,
+	}
+	if err := imp.Run(ctx); err != nil {
+		t.Fatalf("import sync: %v", err)
+	}
+
+	verifyConn := connect(t, connStr)
+	var url string
+	if err := verifyConn.QueryRow(ctx, "SELECT url FROM website WHERE id = 1").Scan(&url); err != nil {
+		t.Fatalf("verify restored: %v", err)
+	}
+	if url != "https://acme.example.com" {
+		t.Errorf("expected website 1 restored, got %s", url)
+	}
+
+	for id, want := range map[int]int{99: 0, 98: 1} {
+		var got int
+		if err := verifyConn.QueryRow(ctx, "SELECT count(*) FROM website WHERE id = $1", id).Scan(&got); err != nil {
+			t.Fatalf("count website %d: %v", id, err)
+		}
+		if got != want {
+			t.Errorf("website %d: want %d rows, got %d", id, want, got)
+		}
+	}
+}
+
+// TestE2E_SyncSharedLookup syncs one company while another company shares a
+// tag with it: the tag leaves the first company's subset but must survive.
+func TestE2E_SyncSharedLookup(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test in short mode")
+	}
+
+	connStr := startPostgres(t)
+	ctx := context.Background()
+
+	setupConn := connect(t, connStr)
+	execSQLFile(t, setupConn, "testdata/e2e/company/setup.sql")
+
+	store := NewMemStore()
+	exp := &Export{
+		DB:           connect(t, connStr),
+		RootTable:    "company",
+		Filter:       "WHERE id = 1",
+		Store:        store,
+		NoAnimations: true,
+	}
+	if err := exp.Run(ctx); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	mutateConn := connect(t, connStr)
+	for _, q := range []string{
+		"INSERT INTO tag (id, name) VALUES (50, 'shared')",
+		"INSERT INTO company_tag (company_id, tag_id) VALUES (1, 50), (2, 50)",
+	} {
+		if _, err := mutateConn.Exec(ctx, q); err != nil {
+			t.Fatalf("mutate %s: %v", q, err)
+		}
+	}
+
+	imp := &Import{
+		DB:           connect(t, connStr),
+		RootTable:    "company",
+		Sync:         true,
+		Store:        store,
+		NoAnimations: true,
+	}
+	if err := imp.Run(ctx); err != nil {
+		t.Fatalf("import sync: %v", err)
+	}
+
+	verifyConn := connect(t, connStr)
+	for query, want := range map[string]int{
+		"SELECT count(*) FROM company_tag WHERE company_id = 1 AND tag_id = 50": 0, // in subset, not in backup: deleted
+		"SELECT count(*) FROM company_tag WHERE company_id = 2 AND tag_id = 50": 1, // other root: kept
+		"SELECT count(*) FROM tag WHERE id = 50":                                1, // shared lookup: kept
+	} {
+		var got int
+		if err := verifyConn.QueryRow(ctx, query).Scan(&got); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		if got != want {
+			t.Errorf("%s: want %d, got %d", query, want, got)
+		}
+	}
+}
+
+func TestE2E_UpsertPolicyConstraint(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test in short mode")
+	}
+
+	connStr := startPostgres(t)
+	ctx := context.Background()
+
+	conn := connect(t, connStr)
+	execSQLFile(t, conn, "testdata/e2e/company/setup.sql")
+
+	policies, err := UpsertPolicies{
+		"company_tag": {Constraint: "company_tag_pkey"},
+		"company":     {},
+	}.resolveConstraints(ctx, conn, nil)
+	if err != nil {
+		t.Fatalf("resolveConstraints: %v", err)
+	}
+	if got := policies["company_tag"].constraintCols; !slices.Equal(got, []string{"company_id", "tag_id"}) {
+		t.Errorf("company_tag_pkey columns: got %v", got)
+	}
+	if got := policies["company"].constraintCols; got != nil {
+		t.Errorf("company: got constraint columns %v", got)
+	}
+
+	if _, err := (UpsertPolicies{"company": {Constraint: "no_such_key"}}).resolveConstraints(ctx, conn, nil); err == nil {
+		t.Error("expected an error for an unknown constraint")
+	}
+}
+
+func TestE2E_RetryRejected(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test in short mode")
+	}
+
+	connStr := startPostgres(t)
+	ctx := context.Background()
+
+	setupConn := connect(t, connStr)
+	execSQLFile(t, setupConn, "testdata/e2e/company/setup.sql")
+
+	store := NewMemStore()
+	exp := &Export{
+		DB:           connect(t, connStr),
+		RootTable:    "company",
+		Filter:       "WHERE id = 1",
+		Store:        store,
+		NoAnimations: true,
+	}
+	if err := exp.Run(ctx); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	truncateAll(t, setupConn)
+	if _, err := setupConn.Exec(ctx, "ALTER TABLE website ADD CONSTRAINT website_url_check CHECK (url <> 'https://acme.example.com')"); err != nil {
+		t.Fatalf("add constraint: %v", err)
+	}
+
+	imp := &Import{
+		DB:           connect(t, connStr),
+		RootTable:    "company",
+		SkipErrors:   true,
+		MaxErrors:    -1,
+		Store:        store,
+		NoAnimations: true,
+	}
+	if err := imp.Run(ctx); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+
+	rejected := string(store.files["website.rejected.csv"])
+	if !strings.Contains(rejected, `"https://acme.example.com","23514","website_url_check",`) {
+		t.Errorf("website.rejected.csv missing the failed row:\n%s", rejected)
+	}
+	if !strings.Contains(string(store.files["website_tag.rejected.csv"]), "23503") {
+		t.Errorf("website_tag.rejected.csv missing the FK failure:\n%s", store.files["website_tag.rejected.csv"])
+	}
+
+	// Fix the target, then retry only the rejected rows
+	if _, err := setupConn.Exec(ctx, "ALTER TABLE website DROP CONSTRAINT website_url_check"); err != nil {
+		t.Fatalf("drop constraint: %v", err)
+	}
+	imp = &Import{
+		DB:            connect(t, connStr),
+		RootTable:     "company",
+		SkipErrors:    true,
+		MaxErrors:     -1,
+		RetryRejected: true,
+		Store:         store,
+		NoAnimations:  true,
+	}
+	report, err := imp.RunWithReport(ctx)
+	if err != nil {
+		t.Fatalf("retry rejected: %v", err)
+	}
+	if report.Failed != 0 || report.Inserted == 0 {
+		t.Errorf("retry: want all rows inserted, got inserted=%d failed=%d", report.Inserted, report.Failed)
+	}
+
+	verifyConn := connect(t, connStr)
+	for query, want := range map[string]int{
+		"SELECT count(*) FROM website WHERE company_id = 1":                                                 2,
+		"SELECT count(*) FROM website_description WHERE website_id = 1":                                     1,
+		"SELECT count(*) FROM website_tag wt JOIN website w ON w.id = wt.website_id WHERE w.company_id = 1": 2,
+	} {
+		var got int
+		if err := verifyConn.QueryRow(ctx, query).Scan(&got); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		if got != want {
+			t.Errorf("%s: want %d, got %d", query, want, got)
+		}
+	}
+	if rejected := string(store.files["website.rejected.csv"]); strings.Count(rejected, "\n") != 1 {
+		t.Errorf("website.rejected.csv should only hold the header after a successful retry:\n%s", rejected)
+	}
+}
+
+func TestE2E_SkipErrorsBatched(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test in short mode")
+	}
+
+	connStr := startPostgres(t)
+	ctx := context.Background()
+
+	setupConn := connect(t, connStr)
+	execSQLFile(t, setupConn, "testdata/e2e/company/setup.sql")
+
+	store := NewMemStore()
+	exp := &Export{
+		DB:           connect(t, connStr),
+		RootTable:    "company",
+		Store:        store,
+		NoAnimations: true,
+	}
+	if err := exp.Run(ctx); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	// Rows already in the target fail, missing ones are inserted; batches mix both
+	importWith := func(batchSize int) *ImportReport {
+		t.Helper()
+		if _, err := setupConn.Exec(ctx, "TRUNCATE website_tag, website_description; DELETE FROM website WHERE id <> 2"); err != nil {
+			t.Fatalf("reset: %v", err)
+		}
+		imp := &Import{
+			DB:           connect(t, connStr),
+			RootTable:    "company",
+			SkipErrors:   true,
+			MaxErrors:    -1,
+			BatchSize:    batchSize,
+			Store:        store,
+			NoAnimations: true,
+		}
+		report, err := imp.RunWithReport(ctx)
+		if err != nil {
+			t.Fatalf("import (batch size %d): %v", batchSize, err)
+		}
+		return report
+	}
+
+	want := importWith(1)
+	got := importWith(3)
+	if want.Failed == 0 || want.Inserted == 0 {
+		t.Fatalf("expected both failed and inserted rows, got %+v", want)
+	}
+	for idx, w := range want.Tables {
+		g := got.Tables[idx]
+		if g.Rows != w.Rows || g.Inserted != w.Inserted || g.Skipped != w.Skipped || g.Failed != w.Failed {
+			t.Errorf("table %s: batched %+v, row by row %+v", w.Table, g, w)
+		}
+	}
+}
+
+func TestE2E_BinaryFormat(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test in short mode")
+	}
+
+	connStr := startPostgres(t)
+	ctx := context.Background()
+
+	setupConn := connect(t, connStr)
+	execSQLFile(t, setupConn, "testdata/e2e/company/setup.sql")
+	original := snapshotDB(t, setupConn)
+
+	store := NewMemStore()
+	exp := &Export{
+		DB:           connect(t, connStr),
+		RootTable:    "company",
+		Format:       FormatBinary,
+		Store:        store,
+		NoAnimations: true,
+	}
+	if err := exp.Run(ctx); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if _, ok := store.files["company.bin"]; !ok {
+		t.Fatalf("company.bin not written")
+	}
+	if _, ok := store.files["company.csv"]; ok {
+		t.Fatalf("company.csv written in binary mode")
+	}
+
+	truncateAll(t, connect(t, connStr))
+
+	// Staged through a temp table, the format comes from manifest.json
+	imp := &Import{
+		DB:           connect(t, connStr),
+		RootTable:    "company",
+		Upsert:       true,
+		Store:        store,
+		NoAnimations: true,
+	}
+	if err := imp.Run(ctx); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	compareSnapshots(t, original, snapshotDB(t, connect(t, connStr)))
+
+	imp.SkipErrors = true
+	if err := imp.Run(ctx); err == nil || !strings.Contains(err.Error(), "cannot read binary") {
+		t.Errorf("expected skip-errors to reject binary files, got %v", err)
+	}
+}
+
+func TestE2E_ParquetAndJSONLFormats(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test in short mode")
+	}
+
+	connStr := startPostgres(t)
+	ctx := context.Background()
+
+	setupConn := connect(t, connStr)
+	execSQLFile(t, setupConn, "testdata/e2e/company/setup.sql")
+	original := snapshotDB(t, setupConn)
+
+	for _, format := range []Format{FormatParquet, FormatJSONL} {
+		store := NewMemStore()
+		exp := &Export{
+			DB:           connect(t, connStr),
+			RootTable:    "company",
+			Format:       format,
+			Store:        store,
+			NoAnimations: true,
+		}
+		if err := exp.Run(ctx); err != nil {
+			t.Fatalf("export %s: %v", format, err)
+		}
+		if _, ok := store.files[format.fileName("company")]; !ok {
+			t.Fatalf("%s not written", format.fileName("company"))
+		}
+
+		for _, skipErrors := range []bool{false, true} {
+			truncateAll(t, connect(t, connStr))
+
+			imp := &Import{
+				DB:           connect(t, connStr),
+				RootTable:    "company",
+				SkipErrors:   skipErrors,
+				Store:        store,
+				NoAnimations: true,
+			}
+			if err := imp.Run(ctx); err != nil {
+				t.Fatalf("import %s (skip errors %v): %v", format, skipErrors, err)
+			}
+			compareSnapshots(t, original, snapshotDB(t, connect(t, connStr)))
+		}
+	}
+}
+
+func TestE2E_SQLScript(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test in short mode")
+	}
+
+	connStr := startPostgres(t)
+	ctx := context.Background()
+
+	setupConn := connect(t, connStr)
+	execSQLFile(t, setupConn, "testdata/e2e/company/setup.sql")
+	original := snapshotDB(t, setupConn)
+
+	for _, mode := range []ScriptMode{ScriptCopy, ScriptInsert} {
+		store := NewMemStore()
+		exp := &Export{
+			DB:           connect(t, connStr),
+			RootTable:    "company",
+			SQLScript:    mode,
+			Store:        store,
+			NoAnimations: true,
+		}
+		if err := exp.Run(ctx); err != nil {
+			t.Fatalf("export: %v", err)
+		}
+		script := string(store.files[sqlScriptName])
+		if !strings.HasPrefix(script, "-- pg_mini export of company") || !strings.HasSuffix(script, "COMMIT;\n") {
+			t.Fatalf("unexpected %s script:\n%s", mode, script)
+		}
+		if mode == ScriptCopy {
+			if !strings.Contains(script, "COPY company (") {
+				t.Errorf("copy script has no COPY block for company")
+			}
+			continue
+		}
+
+		// Without COPY blocks the script runs as one simple-protocol batch
+		truncConn := connect(t, connStr)
+		truncateAll(t, truncConn)
+		if _, err := truncConn.Exec(ctx, script); err != nil {
+			t.Fatalf("run insert script: %v", err)
+		}
+		compareSnapshots(t, original, snapshotDB(t, connect(t, connStr)))
+	}
+}
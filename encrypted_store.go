@@ -0,0 +1,395 @@
+package pg_mini
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Encrypted files start with a header, followed by the content sealed with
+// AES-256-GCM in chunks of encChunkSize bytes:
+//
+//	magic    "pgmini\x00" and the format version, 8 bytes
+//	kdf      0 for a raw key, 1 for a passphrase, 1 byte
+//	kdf salt PBKDF2 salt of a passphrase, zero otherwise, 16 bytes
+//	salt     random per file, 16 bytes
+//
+// Each file is sealed with its own key, derived from the master key and the
+// file salt with HKDF. Chunk nonces are the chunk counter plus a flag marking
+// the last chunk, so reordered, dropped or truncated chunks fail to decrypt.
+// The header is authenticated as additional data of every chunk.
+const (
+	encMagic      = "pgmini\x00\x01"
+	encHeaderSize = len(encMagic) + 1 + 16 + 16
+	encChunkSize  = 64 << 10
+	encOverhead   = 16 // GCM tag per chunk
+
+	kdfRawKey     = 0
+	kdfPassphrase = 1
+	// pbkdf2Iterations follows the OWASP recommendation for PBKDF2-SHA256.
+	pbkdf2Iterations = 600_000
+)
+
+// EncryptionKey is the secret of an EncryptedStore: a raw 32 byte key, or a
+// passphrase stretched with PBKDF2. Build one with NewEncryptionKey,
+// ParseEncryptionKey, LoadEncryptionKey or PassphraseKey.
+type EncryptionKey struct {
+	raw []byte // nil for passphrase keys
+
+	passphrase string
+	salt       []byte // kdf salt of files written with this key
+
+	mu      sync.Mutex
+	derived map[string][]byte // kdf salt -> master key
+}
+
+// NewEncryptionKey returns a key from 32 random bytes, e.g. from
+// `openssl rand 32`.
+func NewEncryptionKey(raw []byte) (*EncryptionKey, error) {
+	if len(raw) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(raw))
+	}
+	return &EncryptionKey{raw: bytes.Clone(raw)}, nil
+}
+
+// ParseEncryptionKey returns a key from its base64 encoding, e.g. from
+// `openssl rand -base64 32` stored in an env var.
+func ParseEncryptionKey(text string) (*EncryptionKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(text))
+	if err != nil {
+		return nil, fmt.Errorf("encryption key must be base64: %w", err)
+	}
+	return NewEncryptionKey(raw)
+}
+
+// LoadEncryptionKey reads a key file holding either the 32 raw key bytes or
+// their base64 encoding.
+func LoadEncryptionKey(path string) (*EncryptionKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read encryption key: %w", err)
+	}
+	if len(data) == 32 {
+		return NewEncryptionKey(data)
+	}
+	key, err := ParseEncryptionKey(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// PassphraseKey returns a key derived from passphrase with PBKDF2-SHA256.
+// Files record the salt they were written with, so any PassphraseKey of the
+// same passphrase decrypts them. Deriving takes a moment; it is done once
+// per salt.
+func PassphraseKey(passphrase string) (*EncryptionKey, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("encryption passphrase must not be empty")
+	}
+	k := &EncryptionKey{passphrase: passphrase, salt: make([]byte, 16), derived: map[string][]byte{}}
+	rand.Read(k.salt)
+	if _, err := k.master(kdfPassphrase, k.salt); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func (k *EncryptionKey) kdf() byte {
+	if k.raw != nil {
+		return kdfRawKey
+	}
+	return kdfPassphrase
+}
+
+// master returns the master key for a file written with kdf and salt.
+func (k *EncryptionKey) master(kdf byte, salt []byte) ([]byte, error) {
+	switch {
+	case kdf == kdfRawKey && k.raw != nil:
+		return k.raw, nil
+	case kdf == kdfRawKey:
+		return nil, fmt.Errorf("encrypted with a key, but a passphrase was given")
+	case kdf == kdfPassphrase && k.raw != nil:
+		return nil, fmt.Errorf("encrypted with a passphrase, but a key was given")
+	case kdf != kdfPassphrase:
+		return nil, fmt.Errorf("unknown key derivation %d", kdf)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if key, ok := k.derived[string(salt)]; ok {
+		return key, nil
+	}
+	key, err := pbkdf2.Key(sha256.New, k.passphrase, salt, pbkdf2Iterations, 32)
+	if err != nil {
+		return nil, fmt.Errorf("derive key from passphrase: %w", err)
+	}
+	k.derived[string(salt)] = key
+	return key, nil
+}
+
+// fileCipher returns the AEAD of the file with header.
+func (k *EncryptionKey) fileCipher(header []byte) (cipher.AEAD, error) {
+	kdf := header[len(encMagic)]
+	kdfSalt := header[len(encMagic)+1 : len(encMagic)+17]
+	fileSalt := header[len(encMagic)+17:]
+
+	master, err := k.master(kdf, kdfSalt)
+	if err != nil {
+		return nil, err
+	}
+	fileKey, err := hkdf.Key(sha256.New, master, fileSalt, "pg_mini encrypted store", 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(fileKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptedStore wraps store so that every file is encrypted on Create and
+// decrypted on Open, transparently to Export and Import. Names are not
+// encrypted. If store is an ExtendedStore, so is the returned Store; Stat
+// reports the decrypted size.
+func EncryptedStore(store Store, key *EncryptionKey) Store {
+	s := encryptedStore{store: store, key: key}
+	if es, ok := store.(ExtendedStore); ok {
+		return encryptedExtendedStore{encryptedStore: s, extended: es}
+	}
+	return s
+}
+
+type encryptedStore struct {
+	store Store
+	key   *EncryptionKey
+}
+
+func (s encryptedStore) Create(name string) (io.WriteCloser, error) {
+	header := make([]byte, encHeaderSize)
+	copy(header, encMagic)
+	header[len(encMagic)] = s.key.kdf()
+	copy(header[len(encMagic)+1:], s.key.salt)
+	rand.Read(header[len(encMagic)+17:])
+
+	aead, err := s.key.fileCipher(header)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt %s: %w", name, err)
+	}
+
+	w, err := s.store.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header); err != nil {
+		w.Close()
+		return nil, fmt.Errorf("encrypt %s: %w", name, err)
+	}
+	return &encryptWriter{dst: w, aead: aead, header: header, buf: make([]byte, 0, encChunkSize)}, nil
+}
+
+func (s encryptedStore) Open(name string) (io.ReadCloser, error) {
+	r, err := s.store.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, encHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:len(encMagic)]) != encMagic {
+		r.Close()
+		return nil, fmt.Errorf("decrypt %s: not encrypted by pg_mini, or by an unsupported version", name)
+	}
+	aead, err := s.key.fileCipher(header)
+	if err != nil {
+		r.Close()
+		return nil, fmt.Errorf("decrypt %s: %w", name, err)
+	}
+	return &decryptReader{
+		name:   name,
+		src:    bufio.NewReaderSize(r, encChunkSize+encOverhead),
+		closer: r,
+		aead:   aead,
+		header: header,
+		buf:    make([]byte, encChunkSize+encOverhead),
+	}, nil
+}
+
+type encryptedExtendedStore struct {
+	encryptedStore
+	extended ExtendedStore
+}
+
+func (s encryptedExtendedStore) List(prefix string) ([]string, error) {
+	return s.extended.List(prefix)
+}
+
+func (s encryptedExtendedStore) Stat(name string) (fs.FileInfo, error) {
+	info, err := s.extended.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	return decryptedFileInfo{FileInfo: info}, nil
+}
+
+func (s encryptedExtendedStore) Delete(name string) error {
+	return s.extended.Delete(name)
+}
+
+// decryptedFileInfo reports the size of the content instead of the file.
+type decryptedFileInfo struct {
+	fs.FileInfo
+}
+
+func (fi decryptedFileInfo) Size() int64 {
+	return decryptedSize(fi.FileInfo.Size())
+}
+
+// decryptedSize computes the content size from the size of the file. Every
+// chunk but the last is full, and there is at least one.
+func decryptedSize(size int64) int64 {
+	n := size - int64(encHeaderSize)
+	if n < encOverhead {
+		return 0
+	}
+	chunks := (n + encChunkSize + encOverhead - 1) / (encChunkSize + encOverhead)
+	return n - chunks*encOverhead
+}
+
+// chunkNonce is the nonce of chunk number counter.
+func chunkNonce(counter uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce, counter)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// encryptWriter seals full chunks as they fill up. The last chunk, possibly
+// full or empty, is sealed on Close.
+type encryptWriter struct {
+	dst     io.WriteCloser
+	aead    cipher.AEAD
+	header  []byte
+	counter uint64
+	buf     []byte
+	err     error
+}
+
+func (w *encryptWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n := len(p)
+	for len(p) > 0 {
+		if len(w.buf) == encChunkSize {
+			if err := w.seal(false); err != nil {
+				return 0, err
+			}
+		}
+		free := min(encChunkSize-len(w.buf), len(p))
+		w.buf = append(w.buf, p[:free]...)
+		p = p[free:]
+	}
+	return n, nil
+}
+
+func (w *encryptWriter) seal(last bool) error {
+	out := w.aead.Seal(nil, chunkNonce(w.counter, last), w.buf, w.header)
+	if _, err := w.dst.Write(out); err != nil {
+		w.err = err
+		return err
+	}
+	w.counter++
+	w.buf = w.buf[:0]
+	return nil
+}
+
+func (w *encryptWriter) Close() error {
+	if w.err == nil {
+		w.seal(true)
+	}
+	err := w.dst.Close()
+	if w.err != nil {
+		return w.err
+	}
+	return err
+}
+
+// decryptReader opens one chunk at a time, failing on the first chunk that
+// does not authenticate.
+type decryptReader struct {
+	name    string
+	src     *bufio.Reader
+	closer  io.Closer
+	aead    cipher.AEAD
+	header  []byte
+	counter uint64
+	buf     []byte // sealed chunk
+	plain   []byte // unread part of the opened chunk
+	done    bool   // the last chunk was opened
+	err     error
+}
+
+func (r *decryptReader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 && r.err == nil {
+		if r.done {
+			r.err = io.EOF
+			break
+		}
+		r.err = r.next()
+	}
+	if len(r.plain) > 0 {
+		n := copy(p, r.plain)
+		r.plain = r.plain[n:]
+		return n, nil
+	}
+	return 0, r.err
+}
+
+// next opens the next chunk. A short chunk, or a full one at the end of the
+// file, must be the last.
+func (r *decryptReader) next() error {
+	n, err := io.ReadFull(r.src, r.buf)
+	switch {
+	case errors.Is(err, io.EOF):
+		return fmt.Errorf("decrypt %s: file is truncated", r.name)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		r.done = true
+	case err != nil:
+		return err
+	default:
+		if _, err := r.src.Peek(1); errors.Is(err, io.EOF) {
+			r.done = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	plain, err := r.aead.Open(r.buf[:0], chunkNonce(r.counter, r.done), r.buf[:n], r.header)
+	if err != nil {
+		return fmt.Errorf("decrypt %s: chunk %d: wrong key, or the file is corrupt or truncated", r.name, r.counter)
+	}
+	r.counter++
+	r.plain = plain
+	return nil
+}
+
+func (r *decryptReader) Close() error {
+	return r.closer.Close()
+}
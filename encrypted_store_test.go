@@ -0,0 +1,193 @@
+package pg_mini
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testEncryptionKey(t *testing.T, b byte) *EncryptionKey {
+	t.Helper()
+	key, err := NewEncryptionKey(bytes.Repeat([]byte{b}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestEncryptedStore_RoundTrip(t *testing.T) {
+	inner := NewMemStore()
+	store := EncryptedStore(inner, testEncryptionKey(t, 1))
+
+	sizes := []int{0, 1, encChunkSize - 1, encChunkSize, encChunkSize + 1, 3*encChunkSize + 17}
+	for _, size := range sizes {
+		want := strings.Repeat("id,name\n1,Acme\n", size/15+1)[:size]
+		name := fmt.Sprintf("sizes/%d.csv", size)
+		writeMember(t, store, name, want)
+		if got := readMember(t, store, name); got != want {
+			t.Fatalf("size %d: read back %d bytes that differ", size, len(got))
+		}
+
+		raw := readMember(t, inner, name)
+		if size > 0 && strings.Contains(raw, "Acme") {
+			t.Fatalf("size %d: plaintext stored unencrypted", size)
+		}
+		info, err := store.(ExtendedStore).Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() != int64(size) {
+			t.Errorf("size %d: Stat reports %d (file is %d bytes)", size, info.Size(), len(raw))
+		}
+	}
+
+	if _, err := store.Open("missing.csv"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("open missing: got %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestEncryptedStore_Tampering(t *testing.T) {
+	content := strings.Repeat("0123456789", 2*encChunkSize/10)
+
+	for _, tt := range []struct {
+		name   string
+		modify func(data []byte) []byte
+	}{
+		{name: "flipped bit", modify: func(data []byte) []byte { data[encHeaderSize+100] ^= 1; return data }},
+		{name: "flipped header", modify: func(data []byte) []byte { data[encHeaderSize-1] ^= 1; return data }},
+		{name: "truncated at chunk", modify: func(data []byte) []byte { return data[:encHeaderSize+encChunkSize+encOverhead] }},
+		{name: "truncated mid chunk", modify: func(data []byte) []byte { return data[:len(data)-5] }},
+		{name: "header only", modify: func(data []byte) []byte { return data[:encHeaderSize] }},
+		{name: "not encrypted", modify: func(data []byte) []byte { return []byte("id,name\n") }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := NewMemStore()
+			store := EncryptedStore(inner, testEncryptionKey(t, 1))
+			writeMember(t, store, "data.csv", content)
+			writeMember(t, inner, "data.csv", string(tt.modify([]byte(readMember(t, inner, "data.csv")))))
+
+			r, err := store.Open("data.csv")
+			if err == nil {
+				_, err = io.ReadAll(r)
+				r.Close()
+			}
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+
+	inner := NewMemStore()
+	writeMember(t, EncryptedStore(inner, testEncryptionKey(t, 1)), "data.csv", content)
+	r, err := EncryptedStore(inner, testEncryptionKey(t, 2)).Open("data.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err := io.ReadAll(r); err == nil || !strings.Contains(err.Error(), "wrong key") {
+		t.Errorf("wrong key: got %v", err)
+	}
+}
+
+func TestEncryptedStore_Passphrase(t *testing.T) {
+	inner := NewMemStore()
+	writer, err := PassphraseKey("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeMember(t, EncryptedStore(inner, writer), "schema.json", `{"tables":[]}`)
+
+	// A new key of the same passphrase has another salt but reads the file.
+	reader, err := PassphraseKey("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readMember(t, EncryptedStore(inner, reader), "schema.json"); got != `{"tables":[]}` {
+		t.Errorf("got %q", got)
+	}
+
+	if _, err := EncryptedStore(inner, testEncryptionKey(t, 1)).Open("schema.json"); err == nil {
+		t.Error("expected an error opening a passphrase file with a raw key")
+	}
+}
+
+func TestLoadEncryptionKey(t *testing.T) {
+	raw := bytes.Repeat([]byte{7}, 32)
+	dir := t.TempDir()
+
+	rawPath := filepath.Join(dir, "raw.key")
+	os.WriteFile(rawPath, raw, 0o600)
+	textPath := filepath.Join(dir, "text.key")
+	os.WriteFile(textPath, []byte(base64.StdEncoding.EncodeToString(raw)+"\n"), 0o600)
+	shortPath := filepath.Join(dir, "short.key")
+	os.WriteFile(shortPath, []byte(base64.StdEncoding.EncodeToString(raw[:16])), 0o600)
+
+	for _, path := range []string{rawPath, textPath} {
+		key, err := LoadEncryptionKey(path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if !bytes.Equal(key.raw, raw) {
+			t.Errorf("%s: loaded the wrong key", path)
+		}
+	}
+	if _, err := LoadEncryptionKey(shortPath); err == nil {
+		t.Error("expected an error for a 16 byte key")
+	}
+	if _, err := ParseEncryptionKey("not base64!"); err == nil {
+		t.Error("expected an error for invalid base64")
+	}
+}
+
+// TestE2E_EncryptedStore runs an export → import round-trip through an
+// encrypted in-memory Store.
+func TestE2E_EncryptedStore(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test in short mode")
+	}
+
+	connStr := startPostgres(t)
+	ctx := context.Background()
+
+	setupConn := connect(t, connStr)
+	execSQLFile(t, setupConn, "testdata/e2e/company/setup.sql")
+	original := snapshotDB(t, setupConn)
+
+	inner := NewMemStore()
+	exp := &Export{
+		DB:           connect(t, connStr),
+		RootTable:    "company",
+		Store:        EncryptedStore(inner, testEncryptionKey(t, 1)),
+		NoAnimations: true,
+	}
+	if err := exp.Run(ctx); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if schema := readMember(t, inner, "schema.json"); strings.Contains(schema, "company") {
+		t.Fatal("schema.json stored unencrypted")
+	}
+
+	truncateAll(t, connect(t, connStr))
+
+	imp := &Import{
+		DB:           connect(t, connStr),
+		RootTable:    "company",
+		Truncate:     true,
+		Store:        EncryptedStore(inner, testEncryptionKey(t, 1)),
+		NoAnimations: true,
+	}
+	if err := imp.Run(ctx); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+
+	restored := snapshotDB(t, connect(t, connStr))
+	compareSnapshots(t, original, restored)
+}
@@ -1,10 +1,12 @@
 package pg_mini
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"log/slog"
 	"os"
+	"slices"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -21,6 +23,19 @@ type Export struct {
 	// supply your own implementation (S3, GCS, in-memory, ...).
 	Store Store
 
+	// Format is the file format tables are written in: FormatCSV (default),
+	// FormatBinary, FormatParquet or FormatJSONL. manifest.json records it
+	// for Import.
+	Format Format
+
+	// SQLScript additionally renders the subset as data.sql, restorable
+	// without pg_mini: ScriptCopy for psql, ScriptInsert for migration
+	// runners. ScriptNone (default) skips it.
+	SQLScript ScriptMode
+
+	// Observer, if set, receives progress events. See Observer.
+	Observer Observer
+
 	DryRun       bool
 	GraphOnly    bool
 	Verbose      bool
@@ -37,34 +52,50 @@ type Export struct {
 //   - Queries are executed within a transaction for internal consistency
 //   - COPY from commands are used to export these temp tables to CSV
 func (e *Export) Run(ctx context.Context) error {
+	_, err := e.RunWithReport(ctx)
+	return err
+}
+
+// RunWithReport runs the export like Run and returns per-table stats. The
+// report is also saved to the Store as report.json, even if the export fails
+// part way. Dry and graph-only runs return a nil report.
+func (e *Export) RunWithReport(ctx context.Context) (report *ExportReport, err error) {
 	t0 := time.Now()
 
 	if e.Store == nil {
-		return fmt.Errorf("storage is required")
+		return nil, fmt.Errorf("storage is required")
 	}
 	store := e.Store
 
+	if !e.Format.valid() {
+		return nil, fmt.Errorf("invalid format %q: must be csv, binary, parquet or jsonl", e.Format)
+	}
+	format := cmp.Or(e.Format, FormatCSV)
+	if !e.SQLScript.valid() {
+		return nil, fmt.Errorf("invalid sql script mode %q: must be copy or insert", e.SQLScript)
+	}
+
 	// Runs queries to understand your database schema
 	schema, err := queryDBSchema(ctx, e.DB)
 	if err != nil {
-		return fmt.Errorf("get schema: %w", err)
+		return nil, fmt.Errorf("get schema: %w", err)
 	}
 
 	// Build a dependency graph of tables based on foreign key relationships (including transitive dependencies!)
 	// Provided with a root table an execution sequence is calculated to traverse the tree
 	graph, err := buildGraph(schema, e.RootTable)
 	if err != nil {
-		return fmt.Errorf("build graph: %w", err)
+		return nil, fmt.Errorf("build graph: %w", err)
 	}
 
-	queries := generateExportQueries(graph, e.Filter, e.RawQuery)
+	queries := generateExportQueries(graph, e.Filter, e.RawQuery, format)
 
 	if e.GraphOnly {
 		if err := saveJSON(store, "graph.json", graph); err != nil {
-			return fmt.Errorf("save graph: %w", err)
+			return nil, fmt.Errorf("save graph: %w", err)
 		}
 		slog.Info("Export graph saved to: graph.json")
-		return nil
+		return nil, nil
 	}
 
 	if e.DryRun {
@@ -84,21 +115,28 @@ func (e *Export) Run(ctx context.Context) error {
 		fmt.Println()
 
 		slog.Info("Dry run complete")
-		return nil
+		return nil, nil
+	}
+
+	// Read before it is overwritten, to find files of the previous export
+	prevManifest, err := loadManifest(store)
+	if err != nil {
+		slog.Warn("Ignoring unreadable manifest of the previous export", "error", err)
+		prevManifest = &manifest{}
 	}
 
 	if err := saveJSON(store, "schema.json", schema); err != nil {
-		return fmt.Errorf("save schema: %w", err)
+		return nil, fmt.Errorf("save schema: %w", err)
 	}
 	slog.Debug("Extracted schema from database, saved to: schema.json")
 
 	if err := saveJSON(store, "graph.json", graph); err != nil {
-		return fmt.Errorf("save graph: %w", err)
+		return nil, fmt.Errorf("save graph: %w", err)
 	}
 	slog.Debug("Export graph calculated, saved to: graph.json")
 
 	if err := saveJSON(store, "export_queries.json", queries); err != nil {
-		return fmt.Errorf("save queries: %w", err)
+		return nil, fmt.Errorf("save queries: %w", err)
 	}
 
 	graphPrinter := &GraphPrinter{
@@ -111,19 +149,39 @@ func (e *Export) Run(ctx context.Context) error {
 		graph.Print()
 	}
 
+	events := notifier{op: "export", obs: multiObserver{graphPrinter, e.Observer}}
+
+	report = &ExportReport{
+		RootTable: e.RootTable,
+		Filter:    e.Filter,
+		RawQuery:  e.RawQuery,
+		Started:   t0,
+	}
+	defer func() {
+		report.finish(t0, err)
+		events.send(Event{Kind: EventFinished, Rows: report.Rows, Bytes: report.Bytes, Duration: report.Duration, Error: report.Error})
+		if serr := saveJSON(store, exportReportName, report); serr != nil {
+			if err == nil {
+				err = fmt.Errorf("save report: %w", serr)
+			} else {
+				slog.Warn("Failed to save report", "error", serr)
+			}
+		}
+	}()
+
 	// Execute temp copy queries in transaction for consistency
 	if e.Verbose || e.NoAnimations {
 		slog.Info("Begin transaction, copying data into temporary tables...")
 	}
 	tx, err := e.DB.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("begin transaction: %w", err)
+		return report, fmt.Errorf("begin transaction: %w", err)
 	}
 	defer tx.Rollback(ctx)
 
+	copyDurations := map[string]time.Duration{}
 	for _, tq := range queries {
-		graph.Tables[tq.Table].status = statusCopyStarted
-		graphPrinter.Render()
+		events.send(Event{Kind: EventTableStarted, Table: tq.Table})
 
 		tblStart := time.Now()
 		var rows int64
@@ -131,7 +189,7 @@ func (e *Export) Run(ctx context.Context) error {
 		slog.Debug(tq.CreateTmp)
 		r, err := tx.Exec(ctx, tq.CreateTmp)
 		if err != nil {
-			return fmt.Errorf("execute query %s: %w", tq.CreateTmp, err)
+			return report, fmt.Errorf("execute query %s: %w", tq.CreateTmp, err)
 		}
 		slog.Debug(r.String())
 		rows += r.RowsAffected()
@@ -140,20 +198,18 @@ func (e *Export) Run(ctx context.Context) error {
 			slog.Debug(tq.CreateIndex)
 			r, err = tx.Exec(ctx, tq.CreateIndex)
 			if err != nil {
-				return fmt.Errorf("execute query %s: %w", tq.CreateIndex, err)
+				return report, fmt.Errorf("execute query %s: %w", tq.CreateIndex, err)
 			}
 			slog.Debug(r.String())
 		}
 
-		graph.Tables[tq.Table].status = statusCopyDone
-		graph.Tables[tq.Table].rows = rows
-		graph.Tables[tq.Table].copyDuration = time.Since(tblStart)
+		copyDurations[tq.Table] = time.Since(tblStart)
 		if e.NoAnimations || e.Verbose {
 			slog.Info("Copied temp table: "+tq.Table, "rows", prettyCount(rows),
-				"duration", prettyDuration(graph.Tables[tq.Table].copyDuration),
+				"duration", prettyDuration(copyDurations[tq.Table]),
 			)
 		}
-		graphPrinter.Render()
+		events.send(Event{Kind: EventTempCopied, Table: tq.Table, Rows: rows, Duration: copyDurations[tq.Table]})
 	}
 
 	if e.Verbose || e.NoAnimations {
@@ -161,27 +217,39 @@ func (e *Export) Run(ctx context.Context) error {
 	}
 	err = tx.Commit(ctx)
 	if err != nil {
-		return fmt.Errorf("commit transaction: %w", err)
+		return report, fmt.Errorf("commit transaction: %w", err)
 	}
 
 	// COPY from commands are used to export these temp tables to CSV
+	manifest := &manifest{}
 	for _, tq := range queries {
 		tblStart := time.Now()
 
-		graph.Tables[tq.Table].status = statusCSVStarted
-		graphPrinter.Render()
+		events.send(Event{Kind: EventCSVStarted, Table: tq.Table})
 
 		slog.Debug(tq.CopyToCSV)
 
-		res, err := copyToCSV(ctx, e.DB, store, tq.Table, tq.CopyToCSV)
+		var res *copyOutRes
+		if format == FormatParquet {
+			res, err = copyToParquet(ctx, e.DB, store, tq.File, tq.CopyToCSV, schema.Tables[tq.Table], events.progress(tq.Table))
+		} else {
+			res, err = copyOut(ctx, e.DB, store, tq.File, tq.CopyToCSV, events.progress(tq.Table))
+		}
 		if err != nil {
-			return fmt.Errorf("copy out files: %w", err)
+			return report, fmt.Errorf("copy out files: %w", err)
 		}
-
-		graph.Tables[tq.Table].status = statusCSVDone
-		graph.Tables[tq.Table].csvSize = res.FileSize
-		graph.Tables[tq.Table].csvDuration = time.Since(tblStart)
-		graphPrinter.Render()
+		csvDuration := time.Since(tblStart)
+
+		report.Tables = append(report.Tables, ExportTableReport{
+			Table:        tq.Table,
+			File:         res.FileName,
+			Rows:         res.Rows,
+			Bytes:        res.FileSize,
+			CopyDuration: copyDurations[tq.Table],
+			CSVDuration:  csvDuration,
+		})
+		manifest.Tables = append(manifest.Tables, manifestTable{Table: tq.Table, File: res.FileName, Format: format})
+		events.send(Event{Kind: EventTableDone, Table: tq.Table, Rows: res.Rows, Bytes: res.FileSize, Duration: csvDuration})
 
 		if e.NoAnimations || e.Verbose {
 			slog.Info("Exported table: "+tq.Table,
@@ -193,7 +261,81 @@ func (e *Export) Run(ctx context.Context) error {
 		}
 	}
 
+	if e.SQLScript != ScriptNone {
+		res, err := writeSQLScript(ctx, e.DB, store, graph, schema, e.SQLScript, nil)
+		if err != nil {
+			return report, fmt.Errorf("write sql script: %w", err)
+		}
+		manifest.Script = res.FileName
+		slog.Info("SQL script written: "+res.FileName,
+			"rows", prettyCount(res.Rows),
+			"duration", prettyDuration(res.Duration),
+			"file size", prettyFileSize(res.FileSize),
+		)
+	}
+
+	if err := saveJSON(store, manifestName, manifest); err != nil {
+		return report, fmt.Errorf("save manifest: %w", err)
+	}
+
+	if es, ok := store.(ExtendedStore); ok {
+		removed, err := removeStaleFiles(es, prevManifest, manifest, schema)
+		if err != nil {
+			return report, fmt.Errorf("remove stale files: %w", err)
+		}
+		if len(removed) > 0 {
+			slog.Info("Removed files of a previous export", "files", removed)
+		}
+	}
+
 	slog.Info("Export complete", "total duration", prettyDuration(time.Since(t0)))
 
-	return nil
+	return report, nil
+}
+
+// removeStaleFiles deletes files a previous export to the same Store left
+// behind and this one did not rewrite: tables in another format or no longer
+// exported, an old data.sql, and the reports, rejected rows and rejected.json
+// of imports of the previous backup. Only names pg_mini writes are considered, so unrelated
+// files next to the backup are kept.
+func removeStaleFiles(store ExtendedStore, prev, cur *manifest, schema *Schema) ([]string, error) {
+	written := map[string]bool{cur.Script: true}
+	for _, t := range cur.Tables {
+		written[t.File] = true
+	}
+
+	candidates := []string{sqlScriptName, importReportName, rejectedManifest, prev.Script}
+	for _, t := range prev.Tables {
+		candidates = append(candidates, t.File)
+	}
+	for tbl := range schema.Tables {
+		for _, format := range []Format{FormatCSV, FormatBinary, FormatParquet, FormatJSONL} {
+			candidates = append(candidates, format.fileName(tbl))
+		}
+		candidates = append(candidates, rejectedFileName(tbl))
+	}
+	slices.Sort(candidates)
+	candidates = slices.Compact(candidates)
+
+	// One listing instead of a Stat per candidate, which would be a request
+	// per name on remote stores
+	existing, err := store.List("")
+	if err != nil {
+		return nil, fmt.Errorf("list: %w", err)
+	}
+
+	var removed []string
+	for _, name := range candidates {
+		if name == "" || written[name] {
+			continue
+		}
+		if _, found := slices.BinarySearch(existing, name); !found {
+			continue
+		}
+		if err := store.Delete(name); err != nil {
+			return removed, fmt.Errorf("delete %s: %w", name, err)
+		}
+		removed = append(removed, name)
+	}
+	return removed, nil
 }
@@ -0,0 +1,183 @@
+package pg_mini
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"strings"
+)
+
+// Format is the file format tables are exported in.
+type Format string
+
+const (
+	// FormatCSV writes <table>.csv with COPY ... (FORMAT csv) (default).
+	FormatCSV Format = "csv"
+	// FormatBinary writes <table>.bin with COPY ... (FORMAT binary). Faster
+	// and smaller for numeric and bytea heavy tables, and exact for floats,
+	// but the target columns must have the same types as the source.
+	FormatBinary Format = "binary"
+	// FormatParquet writes <table>.parquet, typed from the introspected
+	// column types, for analysis in DuckDB, Spark and the like. Import
+	// reads it back.
+	FormatParquet Format = "parquet"
+	// FormatJSONL writes <table>.jsonl, one JSON object per row keyed by
+	// column name. Friendly for diffs and test fixtures.
+	FormatJSONL Format = "jsonl"
+)
+
+func (f Format) valid() bool {
+	switch f {
+	case "", FormatCSV, FormatBinary, FormatParquet, FormatJSONL:
+		return true
+	}
+	return false
+}
+
+// fileName is the Store entry holding tbl in this format.
+func (f Format) fileName(tbl string) string {
+	switch f {
+	case FormatBinary:
+		return tbl + ".bin"
+	case FormatParquet:
+		return tbl + ".parquet"
+	case FormatJSONL:
+		return tbl + ".jsonl"
+	default:
+		return tbl + ".csv"
+	}
+}
+
+// exportQuery is the query writing tmpTbl in this format: a COPY ... TO
+// STDOUT, or for Parquet the SELECT the Parquet writer reads.
+func (f Format) exportQuery(tmpTbl string) string {
+	switch f {
+	case FormatBinary:
+		return fmt.Sprintf("COPY %s TO STDOUT WITH (FORMAT binary);", tmpTbl)
+	case FormatParquet:
+		return fmt.Sprintf("SELECT * FROM %s;", tmpTbl)
+	case FormatJSONL:
+		// CSV with quote and delimiter characters JSON never contains
+		// unescaped, so each row_to_json line is written verbatim
+		return fmt.Sprintf(`COPY (SELECT row_to_json(t) FROM %s AS t) TO STDOUT WITH (FORMAT csv, QUOTE E'\x01', DELIMITER E'\x02');`, tmpTbl)
+	default:
+		return fmt.Sprintf("COPY %s TO STDOUT WITH (FORMAT csv, HEADER, FORCE_QUOTE *);", tmpTbl)
+	}
+}
+
+// importOptions are the COPY ... FROM STDIN options reading this format.
+// Parquet and JSONL files are rendered as CSV on the way in.
+func (f Format) importOptions() string {
+	switch f {
+	case FormatBinary:
+		return "(FORMAT binary)"
+	default:
+		return "CSV HEADER DELIMITER ','"
+	}
+}
+
+// csvSource wraps r, a table file in the given format, so it reads as CSV.
+// cols are the file's columns in COPY order, needed to read JSONL.
+func csvSource(r io.Reader, format Format, cols []columnSchema) (io.ReadCloser, error) {
+	switch format {
+	case FormatParquet:
+		return newParquetCSVReader(r)
+	case FormatJSONL:
+		jr, err := newJSONLCSVReader(r, cols)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(jr), nil
+	default:
+		return io.NopCloser(r), nil
+	}
+}
+
+// manifestName is the Store entry listing the file and format of every
+// exported table.
+const manifestName = "manifest.json"
+
+// checkBinaryTables rejects binary tables that cannot be read back: binary
+// COPY has no text fallback, so every column must be copied into a column of
+// the type it was exported with. drift may be nil.
+func checkBinaryTables(queries []ImportTableQueries, drift *schemaDiff) error {
+	changed := map[string][]string{}
+	if drift != nil {
+		for _, td := range drift.Tables {
+			for _, tc := range td.TypeChanges {
+				changed[td.Table] = append(changed[td.Table], fmt.Sprintf("%s (%s in the backup, %s in the target)", tc.Column, tc.BackupType, tc.TargetType))
+			}
+		}
+	}
+
+	for _, tq := range queries {
+		if tq.Format != FormatBinary {
+			continue
+		}
+		if cols := changed[tq.Target]; len(cols) > 0 {
+			return fmt.Errorf("%s was exported as binary, which cannot be read into changed column types: %s; export it as csv instead",
+				tq.Table, strings.Join(cols, ", "))
+		}
+		if len(tq.textCols) > 0 {
+			return fmt.Errorf("%s was exported as binary, which cannot stage columns of unknown type or missing in the target as text: %s; export it as csv instead",
+				tq.Table, strings.Join(tq.textCols, ", "))
+		}
+	}
+	return nil
+}
+
+// manifest records how each table of an export was written. Backups taken
+// before manifests were written have none; every table is then CSV.
+type manifest struct {
+	Tables []manifestTable // in export order
+	Script string          // data.sql when Export.SQLScript is set
+}
+
+type manifestTable struct {
+	Table  string
+	File   string
+	Format Format
+}
+
+// formats maps every table in the manifest to its format.
+func (m *manifest) formats() map[string]Format {
+	formats := map[string]Format{}
+	for _, t := range m.Tables {
+		formats[t.Table] = t.Format
+	}
+	return formats
+}
+
+// loadManifest reads manifest.json, returning an empty manifest when the
+// Store has none. An ExtendedStore is asked with Stat; for other Stores, which
+// may report a missing name with any error, a failed Open means no manifest.
+func loadManifest(s Store) (*manifest, error) {
+	m := &manifest{}
+	if es, ok := s.(ExtendedStore); ok {
+		_, err := es.Stat(manifestName)
+		if errors.Is(err, fs.ErrNotExist) {
+			return m, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("stat %s: %w", manifestName, err)
+		}
+	}
+
+	f, err := s.Open(manifestName)
+	if err != nil {
+		if _, ok := s.(ExtendedStore); ok {
+			return nil, fmt.Errorf("open %s: %w", manifestName, err)
+		}
+		slog.Debug("No manifest, reading every table as CSV", "error", err)
+		return m, nil
+	}
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(m); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", manifestName, err)
+	}
+	return m, nil
+}
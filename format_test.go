@@ -0,0 +1,144 @@
+package pg_mini
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_generateQueries_BinaryFormat(t *testing.T) {
+	schema := schemaFromFile(t, filepath.Join("testdata/company", "schema.json"))
+	graph, err := buildGraph(schema, "company")
+	if err != nil {
+		t.Fatalf("buildGraph: %v", err)
+	}
+
+	for _, tq := range generateExportQueries(graph, "", "", FormatBinary) {
+		if tq.File != tq.Table+".bin" {
+			t.Errorf("%s: file %q", tq.Table, tq.File)
+		}
+		if !strings.HasSuffix(tq.CopyToCSV, "TO STDOUT WITH (FORMAT binary);") {
+			t.Errorf("%s: copy query %q", tq.Table, tq.CopyToCSV)
+		}
+	}
+
+	// Only company was exported as binary
+	queries := generateImportQueries(graph, schema, importQueryOpts{
+		Formats: map[string]Format{"company": FormatBinary},
+	})
+	for _, tq := range queries {
+		wantFormat, wantOpts := FormatCSV, "WITH CSV HEADER DELIMITER ','"
+		if tq.Table == "company" {
+			wantFormat, wantOpts = FormatBinary, "WITH (FORMAT binary)"
+		}
+		if tq.Format != wantFormat || tq.File != wantFormat.fileName(tq.Table) {
+			t.Errorf("%s: format %q, file %q", tq.Table, tq.Format, tq.File)
+		}
+		if !strings.Contains(tq.Copy, wantOpts) || !strings.Contains(tq.CopyTemp, wantOpts) {
+			t.Errorf("%s: copy queries %q, %q", tq.Table, tq.Copy, tq.CopyTemp)
+		}
+	}
+}
+
+func Test_loadManifest(t *testing.T) {
+	store := NewMemStore()
+
+	// Backups without a manifest are all CSV
+	m, err := loadManifest(store)
+	if err != nil {
+		t.Fatalf("loadManifest: %v", err)
+	}
+	if len(m.Tables) != 0 {
+		t.Errorf("expected empty manifest, got %+v", m)
+	}
+
+	want := &manifest{Tables: []manifestTable{{Table: "company", File: "company.bin", Format: FormatBinary}}}
+	if err := saveJSON(store, manifestName, want); err != nil {
+		t.Fatalf("saveJSON: %v", err)
+	}
+	m, err = loadManifest(store)
+	if err != nil {
+		t.Fatalf("loadManifest: %v", err)
+	}
+	if got := m.formats()["company"]; got != FormatBinary {
+		t.Errorf("company format = %q", got)
+	}
+}
+
+// plainStore is a Store without the ExtendedStore methods, reporting missing
+// names with its own error.
+type plainStore struct {
+	files *MemStore
+}
+
+func (s plainStore) Create(name string) (io.WriteCloser, error) { return s.files.Create(name) }
+
+func (s plainStore) Open(name string) (io.ReadCloser, error) {
+	if _, err := s.files.Stat(name); err != nil {
+		return nil, errors.New("plainStore: no such entry " + name)
+	}
+	return s.files.Open(name)
+}
+
+func Test_loadManifest_PlainStore(t *testing.T) {
+	store := plainStore{files: NewMemStore()}
+
+	m, err := loadManifest(store)
+	if err != nil {
+		t.Fatalf("loadManifest without manifest: %v", err)
+	}
+	if len(m.Tables) != 0 {
+		t.Errorf("expected empty manifest, got %+v", m)
+	}
+
+	want := &manifest{Tables: []manifestTable{{Table: "company", File: "company.bin", Format: FormatBinary}}}
+	if err := saveJSON(store, manifestName, want); err != nil {
+		t.Fatalf("saveJSON: %v", err)
+	}
+	if m, err = loadManifest(store); err != nil {
+		t.Fatalf("loadManifest: %v", err)
+	}
+	if got := m.formats()["company"]; got != FormatBinary {
+		t.Errorf("company format = %q", got)
+	}
+}
+
+func Test_checkBinaryTables_DriftAdapt(t *testing.T) {
+	backup, target := driftTestSchemas()
+	graph, err := buildGraph(backup, "account")
+	if err != nil {
+		t.Fatalf("buildGraph: %v", err)
+	}
+	binary := map[string]Format{"account": FormatBinary}
+	queries := func(target *Schema) []ImportTableQueries {
+		return generateImportQueries(graph, backup, importQueryOpts{Target: target, Formats: binary})
+	}
+
+	// score changed type, fullname is missing in the target
+	diff := diffSchemas(backup, target, graph.ImportOrder)
+	err = checkBinaryTables(queries(target), diff)
+	if err == nil || !strings.Contains(err.Error(), "score (int4 in the backup, numeric in the target)") {
+		t.Errorf("type change: got %v", err)
+	}
+
+	// Only fullname is missing: it would be staged as text
+	target.Tables["account"].Cols[2].Type = "int4"
+	diff = diffSchemas(backup, target, graph.ImportOrder)
+	err = checkBinaryTables(queries(target), diff)
+	if err == nil || !strings.Contains(err.Error(), "as text: fullname") {
+		t.Errorf("dropped column: got %v", err)
+	}
+
+	// The same drift is fine for CSV
+	csv := generateImportQueries(graph, backup, importQueryOpts{Target: target})
+	if err := checkBinaryTables(csv, diff); err != nil {
+		t.Errorf("csv: %v", err)
+	}
+
+	// Binary without drift
+	if err := checkBinaryTables(queries(nil), nil); err != nil {
+		t.Errorf("no drift: %v", err)
+	}
+}
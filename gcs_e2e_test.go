@@ -0,0 +1,147 @@
+package pg_mini
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/fritzkeyzer/pg_mini/gcs_store"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"google.golang.org/api/option"
+)
+
+var _ ExtendedStore = (*gcs_store.Store)(nil)
+
+// startFakeGCS boots a fake-gcs-server container, creates the bucket, and
+// returns a Config pointing at it.
+func startFakeGCS(t *testing.T, bucket string) gcs_store.Config {
+	t.Helper()
+	ctx := context.Background()
+
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "fsouza/fake-gcs-server:1.52.2",
+			Cmd:          []string{"-scheme", "http", "-port", "4443", "-backend", "memory"},
+			ExposedPorts: []string{"4443/tcp"},
+			WaitingFor:   wait.ForHTTP("/storage/v1/b").WithPort("4443/tcp"),
+		},
+		Started: true,
+	})
+	if err != nil {
+		t.Fatalf("start fake-gcs-server container: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := container.Terminate(ctx); err != nil {
+			t.Logf("terminate fake-gcs-server container: %v", err)
+		}
+	})
+
+	endpoint, err := container.PortEndpoint(ctx, "4443/tcp", "http")
+	if err != nil {
+		t.Fatalf("get fake-gcs-server endpoint: %v", err)
+	}
+
+	// Resumable upload sessions are addressed by the server's external URL,
+	// which must match the mapped port.
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint+"/_internal/config",
+		bytes.NewBufferString(fmt.Sprintf(`{"externalUrl": %q}`, endpoint)))
+	if err != nil {
+		t.Fatalf("build config request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("set fake-gcs-server external url: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("set fake-gcs-server external url: %s", resp.Status)
+	}
+
+	cfg := gcs_store.Config{
+		Bucket:   bucket,
+		Endpoint: endpoint + "/storage/v1/",
+		// Small chunks exercise multi-request resumable uploads
+		ChunkSize: 256 * 1024,
+	}
+
+	client, err := storage.NewClient(ctx, option.WithEndpoint(cfg.Endpoint), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("create gcs client: %v", err)
+	}
+	defer client.Close()
+	if err := client.Bucket(bucket).Create(ctx, "pg-mini-test", nil); err != nil {
+		t.Fatalf("create bucket %s: %v", bucket, err)
+	}
+
+	return cfg
+}
+
+func TestE2E_GCSRoundTrip(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test in short mode")
+	}
+
+	ctx := context.Background()
+	connStr := startPostgres(t)
+
+	setupConn := connect(t, connStr)
+	execSQLFile(t, setupConn, "testdata/e2e/company/setup.sql")
+	original := snapshotDB(t, setupConn)
+
+	// Export into GCS under a name prefix.
+	cfg := startFakeGCS(t, "pg-mini-test")
+	cfg.Prefix = "backups/company"
+
+	exportStore, err := gcs_store.New(ctx, cfg)
+	if err != nil {
+		t.Fatalf("new gcs export store: %v", err)
+	}
+	defer exportStore.Close()
+	exp := &Export{
+		DB:           connect(t, connStr),
+		RootTable:    "company",
+		Store:        exportStore,
+		NoAnimations: true,
+	}
+	if err := exp.Run(ctx); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	names, err := exportStore.List("")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(names) == 0 || names[0] == "" {
+		t.Fatalf("list: got %v", names)
+	}
+	if _, err := exportStore.Stat("schema.json"); err != nil {
+		t.Fatalf("stat schema.json: %v", err)
+	}
+
+	truncateAll(t, connect(t, connStr))
+
+	// Import from a fresh Store, proving the objects were durably written.
+	importStore, err := gcs_store.New(ctx, cfg)
+	if err != nil {
+		t.Fatalf("new gcs import store: %v", err)
+	}
+	defer importStore.Close()
+	imp := &Import{
+		DB:           connect(t, connStr),
+		RootTable:    "company",
+		Truncate:     true,
+		Store:        importStore,
+		NoAnimations: true,
+	}
+	if err := imp.Run(ctx); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+
+	restored := snapshotDB(t, connect(t, connStr))
+	compareSnapshots(t, original, restored)
+}
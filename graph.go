@@ -111,3 +111,31 @@ func buildGraph(schema *Schema, rootTbl string) (*Graph, error) {
 
 	return g, nil
 }
+
+// dependentTables returns the root table and every table that references it,
+// directly or transitively, in waves from the root. These are the tables whose
+// rows belong to a single root: tables outside (lookup tables such as tags or
+// countries) may be shared with other roots. Clone mode copies only these
+// tables and sync deletes only from them.
+func dependentTables(g *Graph) []string {
+	result := []string{g.RootTbl}
+	added := map[string]bool{g.RootTbl: true}
+
+	queue := []string{g.RootTbl}
+	for len(queue) > 0 {
+		var nextWave []string
+		for _, tbl := range queue {
+			for _, ref := range g.Tables[tbl].ReferencedByTbl {
+				if !added[ref] {
+					added[ref] = true
+					nextWave = append(nextWave, ref)
+				}
+			}
+		}
+		slices.Sort(nextWave)
+		result = append(result, nextWave...)
+		queue = nextWave
+	}
+
+	return result
+}
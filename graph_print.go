@@ -36,6 +36,32 @@ func (g *GraphPrinter) Render() {
 	g.prevLines = g.g.printAnim(g.w, g.prevLines)
 }
 
+// Event implements Observer: export events update the status shown next to
+// each table, then the graph is rendered again.
+func (g *GraphPrinter) Event(ev Event) {
+	tbl := g.g.Tables[ev.Table]
+	if ev.Op != "export" || tbl == nil {
+		return
+	}
+	switch ev.Kind {
+	case EventTableStarted:
+		tbl.status = statusCopyStarted
+	case EventTempCopied:
+		tbl.status = statusCopyDone
+		tbl.rows = ev.Rows
+		tbl.copyDuration = ev.Duration
+	case EventCSVStarted:
+		tbl.status = statusCSVStarted
+	case EventTableDone:
+		tbl.status = statusCSVDone
+		tbl.csvSize = ev.Bytes
+		tbl.csvDuration = ev.Duration
+	default:
+		return
+	}
+	g.Render()
+}
+
 func (g *Graph) Print() {
 	g.print(os.Stdout, false)
 }
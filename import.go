@@ -1,15 +1,20 @@
 package pg_mini
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"log/slog"
 	"os"
+	"slices"
 	"time"
 
 	"github.com/jackc/pgx/v5"
 )
 
+// DefaultBatchSize is the Import.BatchSize used when none is set.
+const DefaultBatchSize = 1000
+
 type Import struct {
 	DB         *pgx.Conn
 	RootTable  string
@@ -19,11 +24,63 @@ type Import struct {
 	SkipErrors bool
 	MaxErrors  int
 
+	// BatchSize is how many rows SkipErrors sends per round trip. A batch
+	// that fails is split in half until the failing rows are isolated, so
+	// results match inserting every row on its own. Defaults to
+	// DefaultBatchSize; 1 inserts rows one by one.
+	BatchSize int
+
+	// SchemaDrift compares the backup schema against the target database
+	// before importing. See DriftPolicy. The default skips the check.
+	SchemaDrift DriftPolicy
+
+	// UpsertPolicies override, per table, the conflict target and which
+	// columns are updated in Upsert/SoftInsert mode. See UpsertPolicy.
+	UpsertPolicies UpsertPolicies
+
+	// Mapping renames tables and columns and fills new target columns when
+	// the target schema has evolved since the export. See Mapping.
+	Mapping Mapping
+
+	// Clone imports a copy of the root subtree (the root table and every
+	// table referencing it) with new surrogate keys, rewriting foreign keys
+	// to the new rows. Use it to duplicate data within the same database.
+	// Tables outside the subtree are not imported. Combine with Mapping to
+	// transform cloned columns, e.g. Set: {"name": "name || ' (copy)'"}.
+	//
+	// Integer keys without a sequence or default are numbered after the
+	// current maximum. That is not safe against concurrent writers: rows
+	// inserted into the table while the clone runs can take the same keys.
+	Clone bool
+
+	// Sync mirrors the backup into the target, scoped to the exported subset:
+	// every table is upserted, then target rows that belong to the same root
+	// subset (root filter and FK closure, recomputed on the target) but are
+	// missing from the backup are deleted from the root table and the tables
+	// referencing it. Tables the subset only references, such as lookups, are
+	// upserted but never deleted from. Runs in a single transaction. The
+	// export filter should be deterministic (no random() or LIMIT without
+	// ORDER BY).
+	Sync bool
+
+	// Triggers disables triggers on the target for the duration of the
+	// import and restores them afterwards, including on error. See
+	// TriggerPolicy. The default leaves triggers alone.
+	Triggers TriggerPolicy
+
+	// RetryRejected re-imports only the rows a previous SkipErrors run wrote
+	// to <table>.rejected.csv, e.g. after fixing the target. Rows that fail
+	// again are written back to the file. Requires SkipErrors.
+	RetryRejected bool
+
 	// Store is where the export artifacts (schema.json, *.csv, ...) are read
 	// from. Required. Use DirStore(dir) for the local filesystem, or supply
 	// your own implementation (S3, GCS, in-memory, ...).
 	Store Store
 
+	// Observer, if set, receives progress events. See Observer.
+	Observer Observer
+
 	DryRun       bool
 	GraphOnly    bool
 	Verbose      bool
@@ -33,51 +90,186 @@ type Import struct {
 // Run the import
 //   - Loads schema from a previous export
 //   - Builds a dependency graph to determine import order
+//   - Optionally compares the backup schema against the target (SchemaDrift)
 //   - Optionally truncates tables before importing
 //   - Uses COPY FROM to import CSV files in the correct order
 func (i *Import) Run(ctx context.Context) error {
+	_, err := i.RunWithReport(ctx)
+	return err
+}
+
+// RunWithReport runs the import like Run and returns per-table stats. The
+// report is also saved to the Store as import_report.json, not report.json,
+// so the export's report next to it is kept. This happens even if the import
+// fails part way. Dry and graph-only runs return a nil report.
+func (i *Import) RunWithReport(ctx context.Context) (report *ImportReport, err error) {
 	t0 := time.Now()
 
 	if i.Store == nil {
-		return fmt.Errorf("storage is required")
+		return nil, fmt.Errorf("storage is required")
 	}
 	store := i.Store
 
 	schema := &Schema{}
-	err := loadJSON(store, "schema.json", schema)
+	err = loadJSON(store, "schema.json", schema)
 	if err != nil {
-		return fmt.Errorf("load schema: %w", err)
+		return nil, fmt.Errorf("load schema: %w", err)
 	}
 	slog.Debug("Loaded schema from json: schema.json")
 
 	graph, err := buildGraph(schema, i.RootTable)
 	if err != nil {
-		return fmt.Errorf("build graph: %w", err)
+		return nil, fmt.Errorf("build graph: %w", err)
 	}
 
-	queries := generateImportQueries(graph, schema)
+	manifest, err := loadManifest(store)
+	if err != nil {
+		return nil, fmt.Errorf("load manifest: %w", err)
+	}
 
 	if i.MaxErrors < -1 {
-		return fmt.Errorf("--max-errors must be -1 or >= 0")
+		return nil, fmt.Errorf("--max-errors must be -1 or >= 0")
+	}
+	if i.BatchSize < 0 {
+		return nil, fmt.Errorf("batch size must be >= 0")
+	}
+	if i.RetryRejected && (!i.SkipErrors || i.Truncate) {
+		return nil, fmt.Errorf("retry-rejected requires skip-errors and cannot be combined with truncate")
+	}
+	if !i.Triggers.valid() {
+		return nil, fmt.Errorf("invalid trigger policy %q: must be replica or disable", i.Triggers)
+	}
+	if !i.SchemaDrift.valid() {
+		return nil, fmt.Errorf("invalid schema drift policy %q: must be fail, warn or adapt", i.SchemaDrift)
+	}
+
+	if err := i.Mapping.validate(schema); err != nil {
+		return nil, err
+	}
+	if i.Clone && (i.Truncate || i.Upsert || i.SoftInsert || i.SkipErrors) {
+		return nil, fmt.Errorf("clone cannot be combined with truncate, upsert, soft-insert or skip-errors")
+	}
+	if i.Sync && (i.Truncate || i.Upsert || i.SoftInsert || i.SkipErrors || i.Clone) {
+		return nil, fmt.Errorf("sync cannot be combined with truncate, upsert, soft-insert, skip-errors or clone")
+	}
+	if i.Sync && len(i.Mapping) > 0 {
+		return nil, fmt.Errorf("sync cannot be combined with a mapping")
+	}
+
+	upsertPolicies, err := i.UpsertPolicies.resolveConstraints(ctx, i.DB, i.Mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	queryOpts := importQueryOpts{Mapping: i.Mapping, Upsert: upsertPolicies, Formats: manifest.formats()}
+	var drift *schemaDiff
+	if i.SchemaDrift != DriftIgnore {
+		target, err := queryDBSchema(ctx, i.DB)
+		if err != nil {
+			return nil, fmt.Errorf("get target schema: %w", err)
+		}
+
+		// Compare the schema as it will look once mapped onto the target
+		var tables []string
+		for _, tbl := range graph.ImportOrder {
+			tables = append(tables, i.Mapping.target(tbl))
+		}
+		diff := diffSchemas(i.Mapping.apply(schema), target, tables)
+		diff.log()
+		drift = diff
+		if diff.affectsImport() {
+			switch i.SchemaDrift {
+			case DriftFail:
+				return nil, fmt.Errorf("schema drift between backup and target: %s", diff.summary())
+			case DriftAdapt:
+				queryOpts.Target = target
+				for _, tbl := range diff.MissingTables {
+					slog.Warn("Skipping table missing in target", "table", tbl)
+				}
+			}
+		} else {
+			slog.Debug("Backup schema matches target")
+		}
+	}
+
+	if i.Clone {
+		queryOpts.Clone, err = i.cloneOpts(ctx, graph, schema)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	queries := generateImportQueries(graph, schema, queryOpts)
+	if err := upsertPolicies.validate(schema, queries); err != nil {
+		return nil, err
+	}
+	if err := checkBinaryTables(queries, drift); err != nil {
+		return nil, err
+	}
+	if i.SkipErrors {
+		for _, tq := range queries {
+			if tq.Format == FormatBinary {
+				return nil, fmt.Errorf("skip-errors cannot read binary COPY files, %s was exported as binary", tq.Table)
+			}
+		}
+	}
+
+	// Row counts of <table>.rejected.csv, rewritten by every SkipErrors run
+	rejected := map[string]int64{}
+	if i.RetryRejected {
+		if err := loadJSON(store, rejectedManifest, &rejected); err != nil {
+			return nil, fmt.Errorf("load rejected rows: %w", err)
+		}
+		queries = slices.DeleteFunc(queries, func(tq ImportTableQueries) bool {
+			return rejected[tq.Table] == 0
+		})
+		if len(queries) == 0 {
+			slog.Info("No rejected rows to retry")
+			return nil, nil
+		}
 	}
 
 	if i.GraphOnly {
 		if err := saveJSON(store, "graph.json", graph); err != nil {
-			return fmt.Errorf("save graph: %w", err)
+			return nil, fmt.Errorf("save graph: %w", err)
 		}
 		slog.Info("Import graph saved to: graph.json")
-		return nil
+		return nil, nil
+	}
+
+	var targetTables []string
+	for _, tq := range queries {
+		targetTables = append(targetTables, tq.Target)
+	}
+	disableStmts, restoreStmts, err := triggerStatements(ctx, i.DB, i.Triggers, targetTables)
+	if err != nil {
+		return nil, fmt.Errorf("load triggers: %w", err)
 	}
 
 	if i.DryRun {
 		slog.Info("Dry run, not executing queries")
 
 		fmt.Println()
+		for _, stmt := range disableStmts {
+			fmt.Println(stmt)
+		}
 		for _, tq := range queries {
 			if i.Truncate {
 				fmt.Println(tq.Truncate)
 			}
-			if i.SkipErrors {
+			if i.Sync {
+				fmt.Println(tq.CreateTemp)
+				fmt.Println(tq.CopyTemp)
+				fmt.Println(tq.SyncUpsert)
+			} else if i.Clone {
+				fmt.Println(tq.CreateTemp)
+				fmt.Println(tq.CopyTemp)
+				if tq.CloneKeys != "" {
+					fmt.Println(tq.CloneKeys)
+				}
+				fmt.Println(tq.CloneInsert)
+				fmt.Println(tq.DropTemp)
+			} else if i.SkipErrors {
 				if i.Upsert {
 					if tq.RowUpsert != "" {
 						fmt.Println(tq.RowUpsert)
@@ -103,25 +295,60 @@ func (i *Import) Run(ctx context.Context) error {
 				fmt.Println(tq.CopyTemp)
 				fmt.Println(tq.SoftInsert)
 				fmt.Println(tq.DropTemp)
+			} else if tq.Copy == "" {
+				fmt.Println(tq.CreateTemp)
+				fmt.Println(tq.CopyTemp)
+				fmt.Println(tq.InsertTemp)
+				fmt.Println(tq.DropTemp)
 			} else {
 				fmt.Println(tq.Copy)
 			}
 		}
+		if i.Sync {
+			for idx := len(queries) - 1; idx >= 0; idx-- {
+				if queries[idx].SyncDelete != "" {
+					fmt.Println(queries[idx].SyncDelete)
+				}
+			}
+		}
+		for _, stmt := range restoreStmts {
+			fmt.Println(stmt)
+		}
 		fmt.Println()
 
 		slog.Info("Dry run complete")
-		return nil
+		return nil, nil
 	}
 
 	if err := saveJSON(store, "graph.json", graph); err != nil {
-		return fmt.Errorf("save graph: %w", err)
+		return nil, fmt.Errorf("save graph: %w", err)
 	}
 	slog.Debug("Import graph calculated, saved to: graph.json")
 
 	if err := saveJSON(store, "import_queries.json", queries); err != nil {
-		return fmt.Errorf("save queries: %w", err)
+		return nil, fmt.Errorf("save queries: %w", err)
 	}
 
+	events := notifier{op: "import", obs: i.Observer}
+
+	report = &ImportReport{
+		RootTable:  i.RootTable,
+		Mode:       i.mode(),
+		SkipErrors: i.SkipErrors,
+		Started:    t0,
+	}
+	defer func() {
+		report.finish(t0, err)
+		events.send(Event{Kind: EventFinished, Rows: report.Rows, Bytes: report.Bytes, Duration: report.Duration, Error: report.Error})
+		if serr := saveJSON(store, importReportName, report); serr != nil {
+			if err == nil {
+				err = fmt.Errorf("save report: %w", serr)
+			} else {
+				slog.Warn("Failed to save report", "error", serr)
+			}
+		}
+	}()
+
 	graphPrinter := &GraphPrinter{
 		g: graph,
 	}
@@ -131,32 +358,107 @@ func (i *Import) Run(ctx context.Context) error {
 		graph.Print()
 	}
 
+	restoreTriggers, err := disableTriggers(ctx, i.DB, disableStmts, restoreStmts)
+	defer func() {
+		if rerr := restoreTriggers(); rerr != nil {
+			slog.Error("Failed to restore triggers", "error", rerr)
+			if err == nil {
+				err = rerr
+			}
+		}
+	}()
+	if err != nil {
+		return report, err
+	}
+	if len(disableStmts) > 0 && (i.Verbose || i.NoAnimations) {
+		slog.Info("Disabled triggers", "policy", string(i.Triggers))
+	}
+
 	slog.Info("Importing...")
+
+	if i.Sync {
+		if err := i.runSync(ctx, store, queries, dependentTables(graph), report, events); err != nil {
+			return report, err
+		}
+		slog.Info("Import complete", "duration", prettyDuration(time.Since(t0)))
+		return report, nil
+	}
+
 	tableStats := map[string]*rowImportRes{}
-	nullableColsByTable := map[string]map[string]bool{}
 
 	for _, tq := range queries {
+		tblStart := time.Now()
+		events.send(Event{Kind: EventTableStarted, Table: tq.Table})
+
 		if i.Truncate {
 			slog.Debug(tq.Truncate)
 			_, err := i.DB.Exec(ctx, tq.Truncate)
 			if err != nil {
-				return fmt.Errorf("truncate table: %w", err)
+				return report, fmt.Errorf("truncate table: %w", err)
 			}
 			if i.Verbose || i.NoAnimations {
-				slog.Info("Truncated table: " + tq.Table)
+				slog.Info("Truncated table: " + tq.Target)
 			}
 		}
 
-		if i.SkipErrors {
-			nullableCols, ok := nullableColsByTable[tq.Table]
-			if !ok {
-				nullableCols, err = getNullableColumns(ctx, i.DB, tq.Table)
+		if i.Clone {
+			// Create temp table
+			slog.Debug(tq.CreateTemp)
+			_, err := i.DB.Exec(ctx, tq.CreateTemp)
+			if err != nil {
+				return report, fmt.Errorf("create temp table for %s: %w", tq.Table, err)
+			}
+
+			// COPY into temp table
+			slog.Debug(tq.CopyTemp)
+			res, err := copyIn(ctx, i.DB, store, tq, tq.CopyTemp, events.progress(tq.Table))
+			if err != nil {
+				return report, fmt.Errorf("copy from csv into temp table: %w", err)
+			}
+
+			// Map old keys to new keys
+			if tq.CloneKeys != "" {
+				slog.Debug(tq.CloneKeys)
+				_, err = i.DB.Exec(ctx, tq.CloneKeys)
 				if err != nil {
-					return fmt.Errorf("load nullable columns for %s: %w", tq.Table, err)
+					return report, fmt.Errorf("generate keys for %s: %w", tq.Table, err)
 				}
-				nullableColsByTable[tq.Table] = nullableCols
 			}
 
+			// Insert with keys and foreign keys rewritten
+			slog.Debug(tq.CloneInsert)
+			tag, err := i.DB.Exec(ctx, tq.CloneInsert)
+			if err != nil {
+				return report, fmt.Errorf("clone insert for %s: %w", tq.Table, err)
+			}
+
+			// Drop temp table
+			slog.Debug(tq.DropTemp)
+			_, err = i.DB.Exec(ctx, tq.DropTemp)
+			if err != nil {
+				return report, fmt.Errorf("drop temp table for %s: %w", tq.Table, err)
+			}
+
+			report.Tables = append(report.Tables, ImportTableReport{
+				Table:    tq.Table,
+				Target:   tq.Target,
+				Mode:     "clone",
+				File:     res.FileName,
+				Rows:     res.Rows,
+				Bytes:    res.FileSize,
+				Written:  tag.RowsAffected(),
+				Inserted: tag.RowsAffected(),
+				Duration: time.Since(tblStart),
+			})
+
+			if i.Verbose || i.NoAnimations {
+				slog.Info("Cloned: "+tq.Table,
+					"rows", prettyCount(res.Rows),
+					"duration", prettyDuration(res.Duration),
+					"file size", prettyFileSize(res.FileSize),
+				)
+			}
+		} else if i.SkipErrors {
 			mode := "insert"
 			query := tq.Insert
 			usedFallback := false
@@ -179,23 +481,58 @@ func (i *Import) Run(ctx context.Context) error {
 				}
 			}
 
+			src, srcFormat := tq.File, tq.Format
+			if i.RetryRejected {
+				src, srcFormat = rejectedFileName(tq.Table), FormatCSV
+			}
+			rejects := newRejectWriter(store, tq.Table, i.RetryRejected)
+
 			res, err := insertRowsFromCSV(
 				ctx,
 				i.DB,
 				store,
 				tq.Table,
+				src,
+				srcFormat,
+				tq.fileCols,
 				tq.Columns,
-				nullableCols,
+				rowColumnTypes(schema, queryOpts.Target, i.Mapping, tq),
 				query,
 				i.MaxErrors,
 				i.SoftInsert,
+				cmp.Or(i.BatchSize, DefaultBatchSize),
+				rejects,
+				events,
 			)
+			if res != nil {
+				res.Mode = mode
+				res.UsedFallback = usedFallback
+				tableStats[tq.Table] = res
+
+				rejected[tq.Table] = res.Rejected
+				if serr := saveJSON(store, rejectedManifest, rejected); serr != nil && err == nil {
+					err = fmt.Errorf("save rejected rows: %w", serr)
+				}
+
+				report.Tables = append(report.Tables, ImportTableReport{
+					Table:    tq.Table,
+					Target:   tq.Target,
+					Mode:     mode,
+					Fallback: usedFallback,
+					File:     res.FileName,
+					Rows:     res.Processed,
+					Bytes:    res.FileSize,
+					Written:  res.Inserted,
+					Inserted: res.Inserted,
+					Skipped:  res.Skipped,
+					Failed:   res.Failed,
+					Rejected: res.RejectedFile,
+					Duration: res.Duration,
+				})
+			}
 			if err != nil {
-				return fmt.Errorf("row import for %s: %w", tq.Table, err)
+				return report, fmt.Errorf("row import for %s: %w", tq.Table, err)
 			}
-			res.Mode = mode
-			res.UsedFallback = usedFallback
-			tableStats[tq.Table] = res
 
 			if i.Verbose || i.NoAnimations {
 				slog.Info("Imported rows: "+tq.Table,
@@ -213,30 +550,41 @@ func (i *Import) Run(ctx context.Context) error {
 			slog.Debug(tq.CreateTemp)
 			_, err := i.DB.Exec(ctx, tq.CreateTemp)
 			if err != nil {
-				return fmt.Errorf("create temp table for %s: %w", tq.Table, err)
+				return report, fmt.Errorf("create temp table for %s: %w", tq.Table, err)
 			}
 
 			// COPY into temp table
 			slog.Debug(tq.CopyTemp)
-			res, err := copyFromCSV(ctx, i.DB, store, tq.Table, tq.CopyTemp)
+			res, err := copyIn(ctx, i.DB, store, tq, tq.CopyTemp, events.progress(tq.Table))
 			if err != nil {
-				return fmt.Errorf("copy from csv into temp table: %w", err)
+				return report, fmt.Errorf("copy from csv into temp table: %w", err)
 			}
 
 			// Upsert from temp into target
 			slog.Debug(tq.Upsert)
-			_, err = i.DB.Exec(ctx, tq.Upsert)
+			tag, err := i.DB.Exec(ctx, tq.Upsert)
 			if err != nil {
-				return fmt.Errorf("upsert from temp table for %s: %w", tq.Table, err)
+				return report, fmt.Errorf("upsert from temp table for %s: %w", tq.Table, err)
 			}
 
 			// Drop temp table
 			slog.Debug(tq.DropTemp)
 			_, err = i.DB.Exec(ctx, tq.DropTemp)
 			if err != nil {
-				return fmt.Errorf("drop temp table for %s: %w", tq.Table, err)
+				return report, fmt.Errorf("drop temp table for %s: %w", tq.Table, err)
 			}
 
+			report.Tables = append(report.Tables, ImportTableReport{
+				Table:    tq.Table,
+				Target:   tq.Target,
+				Mode:     "upsert",
+				File:     res.FileName,
+				Rows:     res.Rows,
+				Bytes:    res.FileSize,
+				Written:  tag.RowsAffected(),
+				Duration: time.Since(tblStart),
+			})
+
 			if i.Verbose || i.NoAnimations {
 				slog.Info("Upserted: "+tq.Table,
 					"rows", prettyCount(res.Rows),
@@ -249,30 +597,43 @@ func (i *Import) Run(ctx context.Context) error {
 			slog.Debug(tq.CreateTemp)
 			_, err := i.DB.Exec(ctx, tq.CreateTemp)
 			if err != nil {
-				return fmt.Errorf("create temp table for %s: %w", tq.Table, err)
+				return report, fmt.Errorf("create temp table for %s: %w", tq.Table, err)
 			}
 
 			// COPY into temp table
 			slog.Debug(tq.CopyTemp)
-			res, err := copyFromCSV(ctx, i.DB, store, tq.Table, tq.CopyTemp)
+			res, err := copyIn(ctx, i.DB, store, tq, tq.CopyTemp, events.progress(tq.Table))
 			if err != nil {
-				return fmt.Errorf("copy from csv into temp table: %w", err)
+				return report, fmt.Errorf("copy from csv into temp table: %w", err)
 			}
 
 			// Soft insert from temp into target (skip conflicts)
 			slog.Debug(tq.SoftInsert)
-			_, err = i.DB.Exec(ctx, tq.SoftInsert)
+			tag, err := i.DB.Exec(ctx, tq.SoftInsert)
 			if err != nil {
-				return fmt.Errorf("soft insert from temp table for %s: %w", tq.Table, err)
+				return report, fmt.Errorf("soft insert from temp table for %s: %w", tq.Table, err)
 			}
 
 			// Drop temp table
 			slog.Debug(tq.DropTemp)
 			_, err = i.DB.Exec(ctx, tq.DropTemp)
 			if err != nil {
-				return fmt.Errorf("drop temp table for %s: %w", tq.Table, err)
+				return report, fmt.Errorf("drop temp table for %s: %w", tq.Table, err)
 			}
 
+			report.Tables = append(report.Tables, ImportTableReport{
+				Table:    tq.Table,
+				Target:   tq.Target,
+				Mode:     "soft-insert",
+				File:     res.FileName,
+				Rows:     res.Rows,
+				Bytes:    res.FileSize,
+				Written:  tag.RowsAffected(),
+				Inserted: tag.RowsAffected(),
+				Skipped:  res.Rows - tag.RowsAffected(),
+				Duration: time.Since(tblStart),
+			})
+
 			if i.Verbose || i.NoAnimations {
 				slog.Info("Soft inserted: "+tq.Table,
 					"rows", prettyCount(res.Rows),
@@ -280,14 +641,74 @@ func (i *Import) Run(ctx context.Context) error {
 					"file size", prettyFileSize(res.FileSize),
 				)
 			}
+		} else if tq.Copy == "" {
+			// Create temp table
+			slog.Debug(tq.CreateTemp)
+			_, err := i.DB.Exec(ctx, tq.CreateTemp)
+			if err != nil {
+				return report, fmt.Errorf("create temp table for %s: %w", tq.Table, err)
+			}
+
+			// COPY into temp table
+			slog.Debug(tq.CopyTemp)
+			res, err := copyIn(ctx, i.DB, store, tq, tq.CopyTemp, events.progress(tq.Table))
+			if err != nil {
+				return report, fmt.Errorf("copy from csv into temp table: %w", err)
+			}
+
+			// Insert the shared columns from temp into target
+			slog.Debug(tq.InsertTemp)
+			tag, err := i.DB.Exec(ctx, tq.InsertTemp)
+			if err != nil {
+				return report, fmt.Errorf("insert from temp table for %s: %w", tq.Table, err)
+			}
+
+			// Drop temp table
+			slog.Debug(tq.DropTemp)
+			_, err = i.DB.Exec(ctx, tq.DropTemp)
+			if err != nil {
+				return report, fmt.Errorf("drop temp table for %s: %w", tq.Table, err)
+			}
+
+			report.Tables = append(report.Tables, ImportTableReport{
+				Table:    tq.Table,
+				Target:   tq.Target,
+				Mode:     "staged",
+				File:     res.FileName,
+				Rows:     res.Rows,
+				Bytes:    res.FileSize,
+				Written:  tag.RowsAffected(),
+				Inserted: tag.RowsAffected(),
+				Duration: time.Since(tblStart),
+			})
+
+			if i.Verbose || i.NoAnimations {
+				slog.Info("Imported CSV via temp table: "+tq.Table,
+					"rows", prettyCount(res.Rows),
+					"duration", prettyDuration(res.Duration),
+					"file size", prettyFileSize(res.FileSize),
+				)
+			}
 		} else {
 			slog.Debug(tq.Copy)
 
-			res, err := copyFromCSV(ctx, i.DB, store, tq.Table, tq.Copy)
+			res, err := copyIn(ctx, i.DB, store, tq, tq.Copy, events.progress(tq.Table))
 			if err != nil {
-				return fmt.Errorf("copy from csv: %w", err)
+				return report, fmt.Errorf("copy from csv: %w", err)
 			}
 
+			report.Tables = append(report.Tables, ImportTableReport{
+				Table:    tq.Table,
+				Target:   tq.Target,
+				Mode:     "copy",
+				File:     res.FileName,
+				Rows:     res.Rows,
+				Bytes:    res.FileSize,
+				Written:  res.Rows,
+				Inserted: res.Rows,
+				Duration: time.Since(tblStart),
+			})
+
 			if i.Verbose || i.NoAnimations {
 				slog.Info("Imported CSV: "+tq.Table,
 					"rows", prettyCount(res.Rows),
@@ -296,6 +717,21 @@ func (i *Import) Run(ctx context.Context) error {
 				)
 			}
 		}
+
+		tr := report.Tables[len(report.Tables)-1]
+		events.send(Event{Kind: EventTableDone, Table: tq.Table, Rows: tr.Rows, Bytes: tr.Bytes, Duration: tr.Duration})
+	}
+
+	if i.Clone {
+		for _, tq := range queries {
+			if tq.CloneDrop == "" {
+				continue
+			}
+			slog.Debug(tq.CloneDrop)
+			if _, err := i.DB.Exec(ctx, tq.CloneDrop); err != nil {
+				return report, fmt.Errorf("drop key map for %s: %w", tq.Table, err)
+			}
+		}
 	}
 
 	if i.SkipErrors {
@@ -323,6 +759,7 @@ func (i *Import) Run(ctx context.Context) error {
 				"inserted", res.Inserted,
 				"skipped", res.Skipped,
 				"failed", res.Failed,
+				"rejected", res.RejectedFile,
 			)
 		}
 
@@ -336,5 +773,88 @@ func (i *Import) Run(ctx context.Context) error {
 
 	slog.Info("Import complete", "duration", prettyDuration(time.Since(t0)))
 
-	return nil
+	return report, nil
+}
+
+// mode names the import mode in the report.
+func (i *Import) mode() string {
+	switch {
+	case i.Sync:
+		return "sync"
+	case i.Clone:
+		return "clone"
+	case i.Upsert:
+		return "upsert"
+	case i.SoftInsert:
+		return "soft-insert"
+	case i.Truncate:
+		return "truncate"
+	}
+	return "copy"
+}
+
+// cloneOpts resolves the subtree cloned in clone mode and how new keys are
+// generated for each table in it. Keys come from the column's sequence or
+// default; integer keys without one are numbered after the current maximum.
+func (i *Import) cloneOpts(ctx context.Context, graph *Graph, schema *Schema) (*cloneOpts, error) {
+	opts := &cloneOpts{
+		Subtree:  dependentTables(graph),
+		KeyExprs: map[string]string{},
+	}
+
+	for _, tbl := range opts.Subtree {
+		keyCol := cloneKeyCol(graph, schema, tbl)
+		if keyCol == "" {
+			continue
+		}
+		target := i.Mapping.target(tbl)
+		targetCol := i.Mapping.column(tbl, keyCol)
+
+		expr, err := getKeyDefault(ctx, i.DB, target, targetCol)
+		if err != nil {
+			return nil, err
+		}
+		if expr == "" {
+			colType := ""
+			for _, col := range schema.Tables[tbl].Cols {
+				if col.Name == keyCol {
+					colType = col.Type
+				}
+			}
+			if colType != "int2" && colType != "int4" && colType != "int8" {
+				return nil, fmt.Errorf("cannot clone %s: key column %s has no default to generate new values", tbl, keyCol)
+			}
+			expr = maxKeyExpr(target, targetCol)
+		}
+		opts.KeyExprs[tbl] = expr
+	}
+
+	return opts, nil
+}
+
+// rowColumnTypes returns the type of every CSV column bound by the row
+// queries of tq. Mapped tables cast their placeholders to the backup type.
+// Other tables bind straight to the target columns, so a column whose type
+// differs in the target is left as text for the server to parse.
+func rowColumnTypes(schema, target *Schema, mapping Mapping, tq ImportTableQueries) []string {
+	backupTypes := map[string]string{}
+	for _, col := range schema.Tables[tq.Table].Cols {
+		backupTypes[col.Name] = col.Type
+	}
+	var targetTypes map[string]string
+	if _, mapped := mapping[tq.Table]; !mapped && target != nil {
+		targetTypes = map[string]string{}
+		for _, col := range target.Tables[tq.Target].Cols {
+			targetTypes[col.Name] = col.Type
+		}
+	}
+
+	types := make([]string, len(tq.Columns))
+	for idx, col := range tq.Columns {
+		types[idx] = backupTypes[col]
+		if targetTypes != nil && targetTypes[col] != types[idx] {
+			types[idx] = ""
+		}
+	}
+	return types
 }
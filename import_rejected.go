@@ -0,0 +1,128 @@
+package pg_mini
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// rejectedCols are appended to the original CSV columns in a
+// <table>.rejected.csv file.
+var rejectedCols = []string{"_sqlstate", "_constraint", "_error"}
+
+// rejectedManifest is the Store entry recording how many rows of each table
+// were rejected by the last SkipErrors run. RetryRejected only retries the
+// tables listed with a non-zero count.
+const rejectedManifest = "rejected.json"
+
+func rejectedFileName(tbl string) string {
+	return tbl + ".rejected.csv"
+}
+
+// rejectWriter writes rows that failed to import to <table>.rejected.csv,
+// keeping the original CSV values and adding the SQLSTATE, constraint name
+// and error message. The file is only created once a row is rejected, unless
+// always is set (retrying rewrites the file even if every row now succeeds).
+type rejectWriter struct {
+	store  Store
+	name   string
+	always bool
+
+	header []string
+	keep   []int // positions of the original columns in source records
+	f      io.WriteCloser
+	w      *bufio.Writer
+	n      int64
+}
+
+func newRejectWriter(store Store, tbl string, always bool) *rejectWriter {
+	return &rejectWriter{store: store, name: rejectedFileName(tbl), always: always}
+}
+
+// setHeader records the source CSV header. Columns added by an earlier
+// rejection are dropped, so retried rows are not annotated twice.
+func (rw *rejectWriter) setHeader(header []string) {
+	rw.header, rw.keep = nil, nil
+	for idx, col := range header {
+		if slices.Contains(rejectedCols, col) {
+			continue
+		}
+		rw.header = append(rw.header, col)
+		rw.keep = append(rw.keep, idx)
+	}
+	rw.header = append(rw.header, rejectedCols...)
+}
+
+func (rw *rejectWriter) open() error {
+	if rw.f != nil {
+		return nil
+	}
+	f, err := rw.store.Create(rw.name)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", rw.name, err)
+	}
+	rw.f = f
+	rw.w = bufio.NewWriter(f)
+	if rw.header != nil {
+		if err := writeCSVRecord(rw.w, rw.header, nil); err != nil {
+			return fmt.Errorf("write %s: %w", rw.name, err)
+		}
+	}
+	return nil
+}
+
+// write appends a rejected source record, keeping NULLs. record may be
+// short or nil when the CSV line itself could not be parsed.
+func (rw *rejectWriter) write(record []string, nulls []bool, rowErr error) error {
+	if err := rw.open(); err != nil {
+		return err
+	}
+
+	row := make([]string, 0, len(rw.header))
+	rowNulls := make([]bool, 0, len(rw.header))
+	for _, idx := range rw.keep {
+		val, null := "", true
+		if idx < len(record) {
+			val, null = record[idx], idx < len(nulls) && nulls[idx]
+		}
+		row = append(row, val)
+		rowNulls = append(rowNulls, null)
+	}
+	sqlState, constraint, msg := "", "", rowErr.Error()
+	var pgErr *pgconn.PgError
+	if errors.As(rowErr, &pgErr) {
+		sqlState, constraint, msg = pgErr.Code, pgErr.ConstraintName, pgErr.Message
+	}
+	row = append(row, sqlState, constraint, msg)
+	rowNulls = append(rowNulls, sqlState == "", constraint == "", false)
+
+	if err := writeCSVRecord(rw.w, row, rowNulls); err != nil {
+		return fmt.Errorf("write %s: %w", rw.name, err)
+	}
+	rw.n++
+	return nil
+}
+
+// close flushes and closes the file, creating it first if always is set.
+func (rw *rejectWriter) close() error {
+	if rw.f == nil {
+		if !rw.always {
+			return nil
+		}
+		if err := rw.open(); err != nil {
+			return err
+		}
+	}
+	if err := rw.w.Flush(); err != nil {
+		rw.f.Close()
+		return fmt.Errorf("write %s: %w", rw.name, err)
+	}
+	if err := rw.f.Close(); err != nil {
+		return fmt.Errorf("close %s: %w", rw.name, err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package pg_mini
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestRejectWriter(t *testing.T) {
+	store := NewMemStore()
+
+	rw := newRejectWriter(store, "company", false)
+	rw.setHeader([]string{"id", "name"})
+	if err := rw.close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if _, ok := store.files["company.rejected.csv"]; ok {
+		t.Fatalf("file created without rejected rows")
+	}
+
+	rw = newRejectWriter(store, "company", false)
+	rw.setHeader([]string{"id", "name"})
+	pgErr := &pgconn.PgError{Code: "23505", ConstraintName: "company_name_key", Message: "duplicate key"}
+	if err := rw.write([]string{"1", "acme"}, []bool{false, false}, pgErr); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := rw.write([]string{"2"}, []bool{false}, errors.New("bad row")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := rw.close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	want := `"id","name","_sqlstate","_constraint","_error"` + "\n" +
+		`"1","acme","23505","company_name_key","duplicate key"` + "\n" +
+		`"2",,,,"bad row"` + "\n"
+	if got := string(store.files["company.rejected.csv"]); got != want {
+		t.Errorf("rejected file:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Retrying drops the previous annotations and rewrites the file even when
+	// every row succeeds
+	rw = newRejectWriter(store, "company", true)
+	rw.setHeader([]string{"id", "name", "_sqlstate", "_constraint", "_error"})
+	if err := rw.close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if got := string(store.files["company.rejected.csv"]); got != `"id","name","_sqlstate","_constraint","_error"`+"\n" {
+		t.Errorf("retried rejected file = %q", got)
+	}
+}
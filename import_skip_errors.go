@@ -1,9 +1,8 @@
 package pg_mini
 
 import (
-	"bufio"
+	"bytes"
 	"context"
-	"encoding/csv"
 	"errors"
 	"fmt"
 	"io"
@@ -12,6 +11,8 @@ import (
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type rowImportRes struct {
@@ -24,6 +25,8 @@ type rowImportRes struct {
 	FileSize     int64
 	Mode         string
 	UsedFallback bool
+	Rejected     int64  // rows written to RejectedFile
+	RejectedFile string // empty when no row was rejected
 }
 
 func insertRowsFromCSV(
@@ -31,28 +34,61 @@ func insertRowsFromCSV(
 	conn *pgx.Conn,
 	store Store,
 	tbl string,
+	name string,
+	format Format,
+	fileCols []columnSchema,
 	cols []string,
-	nullableCols map[string]bool,
+	colTypes []string,
 	query string,
 	maxErrors int,
 	softInsert bool,
-) (*rowImportRes, error) {
-	name := tbl + ".csv"
+	batchSize int,
+	rejects *rejectWriter,
+	events notifier,
+) (res *rowImportRes, err error) {
 	file, err := store.Open(name)
 	if err != nil {
 		return nil, fmt.Errorf("opening csv file: %w", err)
 	}
 	defer file.Close()
 
-	cr := &countingReader{r: file}
-	r := csv.NewReader(bufio.NewReaderSize(cr, 1024*1024))
-	header, err := r.Read()
+	defer func() {
+		if cerr := rejects.close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		if res != nil {
+			res.Rejected = rejects.n
+			if rejects.f != nil {
+				res.RejectedFile = rejects.name
+			}
+		}
+	}()
+
+	var src io.Reader = file
+	if name == rejects.name {
+		// Retrying rewrites the file being read, so read it all up front
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", name, err)
+		}
+		src = bytes.NewReader(data)
+	}
+
+	cr := &countingReader{r: src, progress: events.progress(tbl)}
+	csvSrc, err := csvSource(cr, format, fileCols)
+	if err != nil {
+		return nil, err
+	}
+	defer csvSrc.Close()
+	r := newCSVReader(csvSrc)
+	header, _, err := r.Read()
 	if err != nil {
 		if errors.Is(err, io.EOF) {
 			return &rowImportRes{FileName: name, FileSize: cr.n}, nil
 		}
 		return nil, fmt.Errorf("reading csv header: %w", err)
 	}
+	rejects.setHeader(header)
 
 	headerPos := make(map[string]int, len(header))
 	for idx, col := range header {
@@ -68,21 +104,38 @@ func insertRowsFromCSV(
 		colIndexes[idx] = pos
 	}
 
-	res := &rowImportRes{
+	decode := newRowDecoder(conn.TypeMap(), colTypes)
+
+	res = &rowImportRes{
 		FileName: name,
 	}
+	ri := &rowInserter{
+		conn:       conn,
+		tbl:        tbl,
+		query:      query,
+		maxErrors:  maxErrors,
+		softInsert: softInsert,
+		batchSize:  max(batchSize, 1),
+		rejects:    rejects,
+		events:     events,
+		res:        res,
+	}
 
 	start := time.Now()
-	line := 1
 
 	for {
-		record, err := r.Read()
+		record, nulls, err := r.Read()
+		line := r.line
 		if err != nil {
 			if errors.Is(err, io.EOF) {
 				break
 			}
+			// Rows queued before this line come first, as if inserted one by one
+			if err := ri.flush(ctx); err != nil {
+				res.Duration = time.Since(start)
+				return res, err
+			}
 			res.Failed++
-			line++
 			if !isDuplicateKeyError(err) {
 				slog.Error("Row import failed",
 					"table", tbl,
@@ -90,77 +143,192 @@ func insertRowsFromCSV(
 					"error", err,
 				)
 			}
-			if maxErrors >= 0 && res.Failed > int64(maxErrors) {
+			if err := ri.reject(line, record, nulls, err); err != nil {
 				res.Duration = time.Since(start)
-				return res, fmt.Errorf("max errors exceeded for table %s: failed=%d max=%d", tbl, res.Failed, maxErrors)
+				return res, err
 			}
 			continue
 		}
 
-		line++
 		res.Processed++
 
 		args := make([]any, len(cols))
-		rowHasError := false
+		var rowErr error
 		for idx, colIdx := range colIndexes {
 			if colIdx >= len(record) {
-				rowHasError = true
-				slog.Error("Row import failed",
-					"table", tbl,
-					"line", line,
-					"error", fmt.Sprintf("column index %d out of range for record with %d values", colIdx, len(record)),
-				)
+				rowErr = fmt.Errorf("column index %d out of range for record with %d values", colIdx, len(record))
 				break
 			}
-			colName := cols[idx]
-			if nullableCols[colName] && record[colIdx] == "" {
+			if nulls[colIdx] {
 				args[idx] = nil
-			} else {
-				args[idx] = record[colIdx]
+				continue
 			}
-		}
-
-		if rowHasError {
-			res.Failed++
-			if maxErrors >= 0 && res.Failed > int64(maxErrors) {
-				res.Duration = time.Since(start)
-				return res, fmt.Errorf("max errors exceeded for table %s: failed=%d max=%d", tbl, res.Failed, maxErrors)
+			args[idx], rowErr = decode(idx, record[colIdx])
+			if rowErr != nil {
+				rowErr = fmt.Errorf("column %s: %w", cols[idx], rowErr)
+				break
 			}
-			continue
 		}
 
-		tag, err := conn.Exec(ctx, query, args...)
-		if err != nil {
-			if softInsert && isDuplicateKeyError(err) {
-				res.Skipped++
-				continue
+		if rowErr != nil {
+			if err := ri.flush(ctx); err != nil {
+				res.Duration = time.Since(start)
+				return res, err
 			}
-
-			res.Failed++
-			slog.Error("Row import failed",
-				"table", tbl,
-				"line", line,
-				"error", err,
-			)
-			if maxErrors >= 0 && res.Failed > int64(maxErrors) {
+			if err := ri.fail(line, record, nulls, rowErr); err != nil {
 				res.Duration = time.Since(start)
-				return res, fmt.Errorf("max errors exceeded for table %s: failed=%d max=%d", tbl, res.Failed, maxErrors)
+				return res, err
 			}
 			continue
 		}
 
-		if tag.RowsAffected() == 0 {
-			res.Skipped++
-		} else {
-			res.Inserted += tag.RowsAffected()
+		if err := ri.add(ctx, pendingRow{line: line, record: record, nulls: nulls, args: args}); err != nil {
+			res.Duration = time.Since(start)
+			return res, err
 		}
 	}
+	if err := ri.flush(ctx); err != nil {
+		res.Duration = time.Since(start)
+		return res, err
+	}
 
 	res.Duration = time.Since(start)
 	res.FileSize = cr.n
 	return res, nil
 }
 
+type pendingRow struct {
+	line   int
+	record []string
+	nulls  []bool
+	args   []any
+}
+
+// rowInserter runs the row-by-row query for queued rows in batches of up to
+// batchSize statements. A batch is a single implicit transaction, so when
+// any row fails none of its rows are kept; the batch is then split in half
+// and each half retried, until the failing rows are isolated and handled one
+// at a time. Halves run in order, so counters, rejected rows and MaxErrors
+// behave exactly as if every row was executed on its own.
+type rowInserter struct {
+	conn       *pgx.Conn
+	tbl        string
+	query      string
+	maxErrors  int
+	softInsert bool
+	batchSize  int
+	rejects    *rejectWriter
+	events     notifier
+	res        *rowImportRes
+
+	pending []pendingRow
+}
+
+func (ri *rowInserter) add(ctx context.Context, row pendingRow) error {
+	ri.pending = append(ri.pending, row)
+	if len(ri.pending) < ri.batchSize {
+		return nil
+	}
+	return ri.flush(ctx)
+}
+
+func (ri *rowInserter) flush(ctx context.Context) error {
+	rows := ri.pending
+	ri.pending = nil
+	return ri.exec(ctx, rows)
+}
+
+func (ri *rowInserter) exec(ctx context.Context, rows []pendingRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	if len(rows) == 1 {
+		return ri.execOne(ctx, rows[0])
+	}
+
+	b := &pgx.Batch{}
+	for _, row := range rows {
+		b.Queue(ri.query, row.args...)
+	}
+	tags, err := execBatch(ctx, ri.conn, b)
+	if err == nil {
+		for _, tag := range tags {
+			ri.count(tag)
+		}
+		return nil
+	}
+
+	mid := len(rows) / 2
+	if err := ri.exec(ctx, rows[:mid]); err != nil {
+		return err
+	}
+	return ri.exec(ctx, rows[mid:])
+}
+
+func (ri *rowInserter) execOne(ctx context.Context, row pendingRow) error {
+	tag, err := ri.conn.Exec(ctx, ri.query, row.args...)
+	if err != nil {
+		if ri.softInsert && isDuplicateKeyError(err) {
+			ri.res.Skipped++
+			return nil
+		}
+		return ri.fail(row.line, row.record, row.nulls, err)
+	}
+	ri.count(tag)
+	return nil
+}
+
+func (ri *rowInserter) count(tag pgconn.CommandTag) {
+	if tag.RowsAffected() == 0 {
+		ri.res.Skipped++
+	} else {
+		ri.res.Inserted += tag.RowsAffected()
+	}
+}
+
+// fail logs and records a failed row.
+func (ri *rowInserter) fail(line int, record []string, nulls []bool, rowErr error) error {
+	ri.res.Failed++
+	slog.Error("Row import failed",
+		"table", ri.tbl,
+		"line", line,
+		"error", rowErr,
+	)
+	return ri.reject(line, record, nulls, rowErr)
+}
+
+// reject writes a failed row to the rejected file and enforces MaxErrors.
+// The row must already be counted as failed.
+func (ri *rowInserter) reject(line int, record []string, nulls []bool, rowErr error) error {
+	ri.events.send(Event{Kind: EventRowFailed, Table: ri.tbl, Line: int64(line), Error: rowErr.Error()})
+	if err := ri.rejects.write(record, nulls, rowErr); err != nil {
+		return err
+	}
+	if ri.maxErrors >= 0 && ri.res.Failed > int64(ri.maxErrors) {
+		return fmt.Errorf("max errors exceeded for table %s: failed=%d max=%d", ri.tbl, ri.res.Failed, ri.maxErrors)
+	}
+	return nil
+}
+
+// execBatch sends b and returns the command tag of every statement, or the
+// first error.
+func execBatch(ctx context.Context, conn *pgx.Conn, b *pgx.Batch) ([]pgconn.CommandTag, error) {
+	br := conn.SendBatch(ctx, b)
+	tags := make([]pgconn.CommandTag, 0, b.Len())
+	for range b.Len() {
+		tag, err := br.Exec()
+		if err != nil {
+			br.Close()
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	if err := br.Close(); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
 func isDuplicateKeyError(err error) bool {
 	return strings.Contains(err.Error(), "(SQLSTATE 23505)")
 	//var pgErr *pgconn.PgError
@@ -170,28 +338,46 @@ func isDuplicateKeyError(err error) bool {
 	//return false
 }
 
-func getNullableColumns(ctx context.Context, conn *pgx.Conn, table string) (map[string]bool, error) {
-	query := `
-		SELECT column_name, is_nullable
-		FROM information_schema.columns
-		WHERE table_schema = 'public' AND table_name = $1
-	`
+// codecTypes are decoded with their pgx codec before binding; their Go values
+// round-trip without loss. Values of other types (json, arrays, enums, ...)
+// are bound as text and parsed by the server with the column's input
+// function.
+var codecTypes = map[string]bool{
+	"bool":    true,
+	"int2":    true,
+	"int4":    true,
+	"int8":    true,
+	"float4":  true,
+	"float8":  true,
+	"numeric": true,
+	"bytea":   true,
+	"uuid":    true,
+	"oid":     true,
+}
 
-	rows, err := conn.Query(ctx, query, table)
-	if err != nil {
-		return nil, fmt.Errorf("querying nullable columns: %w", err)
+// newRowDecoder returns a function turning the CSV text of column idx into a
+// query argument, using the pgx codec for its type (udt_name). Unknown and
+// empty types stay text.
+func newRowDecoder(m *pgtype.Map, colTypes []string) func(idx int, val string) (any, error) {
+	types := make([]*pgtype.Type, len(colTypes))
+	for idx, name := range colTypes {
+		if !codecTypes[name] {
+			continue
+		}
+		if t, ok := m.TypeForName(name); ok {
+			types[idx] = t
+		}
 	}
-	defer rows.Close()
 
-	result := map[string]bool{}
-	for rows.Next() {
-		var columnName string
-		var isNullable string
-		if err := rows.Scan(&columnName, &isNullable); err != nil {
-			return nil, fmt.Errorf("scanning nullable column row: %w", err)
+	return func(idx int, val string) (any, error) {
+		t := types[idx]
+		if t == nil {
+			return val, nil
+		}
+		v, err := t.Codec.DecodeValue(m, t.OID, pgtype.TextFormatCode, []byte(val))
+		if err != nil {
+			return nil, fmt.Errorf("decode %s %q: %w", t.Name, val, err)
 		}
-		result[columnName] = isNullable == "YES"
+		return v, nil
 	}
-
-	return result, nil
 }
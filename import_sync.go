@@ -0,0 +1,175 @@
+package pg_mini
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+type syncRes struct {
+	Rows     int64
+	Bytes    int64
+	Inserted int64
+	Updated  int64
+	Deleted  int64
+	Duration time.Duration
+}
+
+// runSync mirrors the backup into the target, scoped to the exported subset:
+//   - The export queries are re-run on the target, computing which target rows
+//     belong to the same root subset (root filter and FK closure)
+//   - Every table is upserted from a temp table, counting inserts and updates
+//   - Rows of the subset that are missing from the backup are deleted, in
+//     reverse import order, from the dependent tables only. Referenced tables
+//     (lookups such as tags) may be shared with other roots and are never
+//     deleted from
+//
+// Everything runs in a single transaction, so a failure leaves the target
+// untouched.
+func (i *Import) runSync(ctx context.Context, store Store, queries []ImportTableQueries, dependents []string, report *ImportReport, events notifier) error {
+	var exportQueries []ExportTableQueries
+	if err := loadJSON(store, "export_queries.json", &exportQueries); err != nil {
+		return fmt.Errorf("load export queries: %w", err)
+	}
+
+	for _, tq := range queries {
+		if tq.SyncUpsert == "" || (tq.SyncDelete == "" && slices.Contains(dependents, tq.Table)) {
+			return fmt.Errorf("sync requires a primary key or unique constraint on %s", tq.Table)
+		}
+	}
+
+	tx, err := i.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Recompute the exported subset on the target, before any rows change
+	for _, eq := range exportQueries {
+		slog.Debug(eq.CreateTmp)
+		if _, err := tx.Exec(ctx, eq.CreateTmp); err != nil {
+			return fmt.Errorf("select subset of %s: %w", eq.Table, err)
+		}
+		if eq.CreateIndex != "" {
+			slog.Debug(eq.CreateIndex)
+			if _, err := tx.Exec(ctx, eq.CreateIndex); err != nil {
+				return fmt.Errorf("index subset of %s: %w", eq.Table, err)
+			}
+		}
+	}
+
+	stats := map[string]*syncRes{}
+	for _, tq := range queries {
+		tblStart := time.Now()
+		events.send(Event{Kind: EventTableStarted, Table: tq.Table})
+
+		// Create temp table
+		slog.Debug(tq.CreateTemp)
+		if _, err := tx.Exec(ctx, tq.CreateTemp); err != nil {
+			return fmt.Errorf("create temp table for %s: %w", tq.Table, err)
+		}
+
+		// COPY into temp table
+		slog.Debug(tq.CopyTemp)
+		copyRes, err := copyIn(ctx, i.DB, store, tq, tq.CopyTemp, events.progress(tq.Table))
+		if err != nil {
+			return fmt.Errorf("copy from csv into temp table: %w", err)
+		}
+
+		// Upsert from temp into target
+		res := &syncRes{Rows: copyRes.Rows, Bytes: copyRes.FileSize}
+		slog.Debug(tq.SyncUpsert)
+		if err := tx.QueryRow(ctx, tq.SyncUpsert).Scan(&res.Inserted, &res.Updated); err != nil {
+			return fmt.Errorf("upsert from temp table for %s: %w", tq.Table, err)
+		}
+		res.Duration = time.Since(tblStart)
+		stats[tq.Table] = res
+
+		if i.Verbose || i.NoAnimations {
+			slog.Info("Upserted: "+tq.Table,
+				"inserted", prettyCount(res.Inserted),
+				"updated", prettyCount(res.Updated),
+				"duration", prettyDuration(res.Duration),
+			)
+		}
+	}
+
+	// Delete rows of the subset missing from the backup, dependents first
+	for idx := len(queries) - 1; idx >= 0; idx-- {
+		tq := queries[idx]
+		if tq.SyncDelete == "" {
+			continue
+		}
+		tblStart := time.Now()
+
+		slog.Debug(tq.SyncDelete)
+		tag, err := tx.Exec(ctx, tq.SyncDelete)
+		if err != nil {
+			return fmt.Errorf("delete missing rows from %s: %w", tq.Target, err)
+		}
+		res := stats[tq.Table]
+		res.Deleted = tag.RowsAffected()
+		res.Duration += time.Since(tblStart)
+
+		if i.Verbose || i.NoAnimations {
+			slog.Info("Deleted missing rows: "+tq.Table, "deleted", prettyCount(res.Deleted))
+		}
+	}
+
+	for _, tq := range queries {
+		slog.Debug(tq.DropTemp)
+		if _, err := tx.Exec(ctx, tq.DropTemp); err != nil {
+			return fmt.Errorf("drop temp table for %s: %w", tq.Table, err)
+		}
+	}
+	for _, eq := range exportQueries {
+		dropTmp := fmt.Sprintf("DROP TABLE IF EXISTS %s;", tmpTblName(eq.Table))
+		slog.Debug(dropTmp)
+		if _, err := tx.Exec(ctx, dropTmp); err != nil {
+			return fmt.Errorf("drop subset of %s: %w", eq.Table, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+
+	var totalInserted, totalUpdated, totalDeleted int64
+	for _, tq := range queries {
+		res := stats[tq.Table]
+		totalInserted += res.Inserted
+		totalUpdated += res.Updated
+		totalDeleted += res.Deleted
+
+		slog.Info("Table sync stats",
+			"table", tq.Table,
+			"inserted", res.Inserted,
+			"updated", res.Updated,
+			"deleted", res.Deleted,
+		)
+
+		report.Tables = append(report.Tables, ImportTableReport{
+			Table:    tq.Table,
+			Target:   tq.Target,
+			Mode:     "sync",
+			File:     tq.File,
+			Rows:     res.Rows,
+			Bytes:    res.Bytes,
+			Written:  res.Inserted + res.Updated,
+			Inserted: res.Inserted,
+			Updated:  res.Updated,
+			Deleted:  res.Deleted,
+			Duration: res.Duration,
+		})
+		events.send(Event{Kind: EventTableDone, Table: tq.Table, Rows: res.Rows, Bytes: res.Bytes, Duration: res.Duration})
+	}
+	slog.Info("Import sync stats",
+		"inserted", totalInserted,
+		"updated", totalUpdated,
+		"deleted", totalDeleted,
+	)
+
+	return nil
+}
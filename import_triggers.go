@@ -0,0 +1,160 @@
+package pg_mini
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// TriggerPolicy controls whether triggers on the target fire during Import.
+type TriggerPolicy string
+
+const (
+	// TriggersOn leaves triggers alone (default).
+	TriggersOn TriggerPolicy = ""
+	// TriggersReplica sets session_replication_role = replica for the import
+	// session. No user triggers fire, and neither do foreign key checks.
+	// Requires superuser, or the SET privilege on the parameter (PG15+).
+	// Only the import session is affected.
+	TriggersReplica TriggerPolicy = "replica"
+	// TriggersDisable runs ALTER TABLE ... DISABLE TRIGGER for every enabled
+	// user trigger on the imported tables. Foreign key checks still apply.
+	// Requires table ownership. Note that this is visible to other sessions
+	// until the import finishes.
+	TriggersDisable TriggerPolicy = "disable"
+)
+
+func (p TriggerPolicy) valid() bool {
+	switch p {
+	case TriggersOn, TriggersReplica, TriggersDisable:
+		return true
+	}
+	return false
+}
+
+type userTrigger struct {
+	Table string
+	Name  string
+	// Enabled is pg_trigger.tgenabled: 'O' (origin), 'R' (replica) or 'A'
+	// (always).
+	Enabled string
+}
+
+// toggleStatements returns the statements that disable trg and enable it
+// again in its original mode.
+func (trg userTrigger) toggleStatements() (disable, restore string) {
+	table := pgx.Identifier{trg.Table}.Sanitize()
+	name := pgx.Identifier{trg.Name}.Sanitize()
+
+	mode := ""
+	switch trg.Enabled {
+	case "R":
+		mode = "REPLICA "
+	case "A":
+		mode = "ALWAYS "
+	}
+	return fmt.Sprintf("ALTER TABLE %s DISABLE TRIGGER %s;", table, name),
+		fmt.Sprintf("ALTER TABLE %s ENABLE %sTRIGGER %s;", table, mode, name)
+}
+
+// triggerStatements returns the statements that turn triggers off and back
+// on for the given target tables.
+func triggerStatements(ctx context.Context, conn *pgx.Conn, policy TriggerPolicy, tables []string) (disable, restore []string, err error) {
+	switch policy {
+	case TriggersReplica:
+		var prev string
+		if err := conn.QueryRow(ctx, "SHOW session_replication_role").Scan(&prev); err != nil {
+			return nil, nil, fmt.Errorf("show session_replication_role: %w", err)
+		}
+		disable = []string{"SET session_replication_role = replica;"}
+		restore = []string{fmt.Sprintf("SET session_replication_role = %s;", prev)}
+
+	case TriggersDisable:
+		triggers, err := getUserTriggers(ctx, conn, tables)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, trg := range triggers {
+			d, r := trg.toggleStatements()
+			disable = append(disable, d)
+			restore = append(restore, r)
+		}
+	}
+	return disable, restore, nil
+}
+
+// disableTriggers executes the disable statements. The returned function
+// restores every trigger disabled so far; it must be called even if
+// disableTriggers fails, and keeps working after ctx is cancelled.
+func disableTriggers(ctx context.Context, conn *pgx.Conn, disable, restore []string) (func() error, error) {
+	done := 0
+	restoreFn := func() error {
+		ctx := context.WithoutCancel(ctx)
+		var errs []error
+		for _, stmt := range restore[:done] {
+			slog.Debug(stmt)
+			if _, err := conn.Exec(ctx, stmt); err != nil {
+				errs = append(errs, fmt.Errorf("restore triggers: %s: %w", stmt, err))
+			}
+		}
+		return errors.Join(errs...)
+	}
+
+	for _, stmt := range disable {
+		slog.Debug(stmt)
+		if _, err := conn.Exec(ctx, stmt); err != nil {
+			if isInsufficientPrivilegeError(err) {
+				return restoreFn, fmt.Errorf("disable triggers: insufficient privilege for %q "+
+					"(replica mode needs superuser or SET privilege on session_replication_role, "+
+					"disable mode needs table ownership): %w", stmt, err)
+			}
+			return restoreFn, fmt.Errorf("disable triggers: %s: %w", stmt, err)
+		}
+		done++
+	}
+	return restoreFn, nil
+}
+
+func isInsufficientPrivilegeError(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "42501"
+	}
+	return false
+}
+
+// getUserTriggers lists the enabled, user-defined triggers on the given
+// tables, with the mode they are enabled in.
+func getUserTriggers(ctx context.Context, conn *pgx.Conn, tables []string) ([]userTrigger, error) {
+	query := `
+		SELECT c.relname, t.tgname, t.tgenabled::text
+		FROM pg_trigger t
+			JOIN pg_class c ON c.oid = t.tgrelid
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = 'public'
+			AND c.relname = ANY($1)
+			AND NOT t.tgisinternal
+			AND t.tgenabled <> 'D'
+		ORDER BY c.relname, t.tgname
+	`
+
+	rows, err := conn.Query(ctx, query, tables)
+	if err != nil {
+		return nil, fmt.Errorf("querying triggers: %w", err)
+	}
+	defer rows.Close()
+
+	var result []userTrigger
+	for rows.Next() {
+		var trg userTrigger
+		if err := rows.Scan(&trg.Table, &trg.Name, &trg.Enabled); err != nil {
+			return nil, fmt.Errorf("scanning trigger row: %w", err)
+		}
+		result = append(result, trg)
+	}
+	return result, rows.Err()
+}
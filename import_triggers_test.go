@@ -0,0 +1,36 @@
+package pg_mini
+
+import "testing"
+
+func Test_userTrigger_toggleStatements(t *testing.T) {
+	tests := []struct {
+		trg         userTrigger
+		wantDisable string
+		wantRestore string
+	}{
+		{
+			trg:         userTrigger{Table: "company", Name: "audit", Enabled: "O"},
+			wantDisable: `ALTER TABLE "company" DISABLE TRIGGER "audit";`,
+			wantRestore: `ALTER TABLE "company" ENABLE TRIGGER "audit";`,
+		},
+		{
+			trg:         userTrigger{Table: "company", Name: "replicate", Enabled: "R"},
+			wantDisable: `ALTER TABLE "company" DISABLE TRIGGER "replicate";`,
+			wantRestore: `ALTER TABLE "company" ENABLE REPLICA TRIGGER "replicate";`,
+		},
+		{
+			trg:         userTrigger{Table: "Order Items", Name: `weird"name`, Enabled: "A"},
+			wantDisable: `ALTER TABLE "Order Items" DISABLE TRIGGER "weird""name";`,
+			wantRestore: `ALTER TABLE "Order Items" ENABLE ALWAYS TRIGGER "weird""name";`,
+		},
+	}
+	for _, tt := range tests {
+		disable, restore := tt.trg.toggleStatements()
+		if disable != tt.wantDisable {
+			t.Errorf("disable: got %s, want %s", disable, tt.wantDisable)
+		}
+		if restore != tt.wantRestore {
+			t.Errorf("restore: got %s, want %s", restore, tt.wantRestore)
+		}
+	}
+}
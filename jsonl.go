@@ -0,0 +1,158 @@
+package pg_mini
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// jsonlCSVReader renders a JSON Lines file, one object per row keyed by
+// column name, as CSV the way COPY ... (FORMAT csv, HEADER, FORCE_QUOTE *)
+// writes it, so the CSV import paths can load JSONL exports. Columns come out
+// in the order of cols whatever the key order; missing keys and JSON nulls
+// become NULL.
+type jsonlCSVReader struct {
+	sc   *bufio.Scanner
+	cols []columnSchema
+	line int
+	buf  bytes.Buffer
+	w    *bufio.Writer
+	err  error
+}
+
+func newJSONLCSVReader(src io.Reader, cols []columnSchema) (*jsonlCSVReader, error) {
+	jr := &jsonlCSVReader{sc: bufio.NewScanner(src), cols: cols}
+	jr.sc.Buffer(make([]byte, 0, 1024*1024), 256*1024*1024)
+	jr.w = bufio.NewWriter(&jr.buf)
+
+	header := make([]string, len(cols))
+	for idx, col := range cols {
+		header[idx] = col.Name
+	}
+	if err := writeCSVRecord(jr.w, header, nil); err != nil {
+		return nil, err
+	}
+	if err := jr.w.Flush(); err != nil {
+		return nil, err
+	}
+	return jr, nil
+}
+
+func (jr *jsonlCSVReader) Read(p []byte) (int, error) {
+	for jr.buf.Len() == 0 && jr.err == nil {
+		jr.err = jr.next()
+	}
+	if jr.buf.Len() > 0 {
+		return jr.buf.Read(p)
+	}
+	return 0, jr.err
+}
+
+// next renders the next non-blank line into buf, returning io.EOF after the
+// last one.
+func (jr *jsonlCSVReader) next() error {
+	if !jr.sc.Scan() {
+		if err := jr.sc.Err(); err != nil {
+			return fmt.Errorf("reading jsonl: %w", err)
+		}
+		return io.EOF
+	}
+	jr.line++
+
+	line := bytes.TrimSpace(jr.sc.Bytes())
+	if len(line) == 0 {
+		return nil
+	}
+
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(line, &obj); err != nil {
+		return fmt.Errorf("jsonl line %d: %w", jr.line, err)
+	}
+	for key := range obj {
+		if !slices.ContainsFunc(jr.cols, func(col columnSchema) bool { return col.Name == key }) {
+			return fmt.Errorf("jsonl line %d: unknown column %q", jr.line, key)
+		}
+	}
+
+	record := make([]string, len(jr.cols))
+	nulls := make([]bool, len(jr.cols))
+	for idx, col := range jr.cols {
+		raw, ok := obj[col.Name]
+		if !ok || string(raw) == "null" {
+			nulls[idx] = true
+			continue
+		}
+		text, err := jsonText(raw, col.Type == "json" || col.Type == "jsonb")
+		if err != nil {
+			return fmt.Errorf("jsonl line %d: column %s: %w", jr.line, col.Name, err)
+		}
+		record[idx] = text
+	}
+	if err := writeCSVRecord(jr.w, record, nulls); err != nil {
+		return err
+	}
+	return jr.w.Flush()
+}
+
+// jsonText renders a JSON value in PostgreSQL's text input format. Values of
+// json columns are kept as JSON; strings, numbers and booleans are taken as
+// is and arrays become array literals.
+func jsonText(raw json.RawMessage, isJSON bool) (string, error) {
+	if isJSON {
+		return string(raw), nil
+	}
+	switch raw[0] {
+	case '"':
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return "", err
+		}
+		return s, nil
+	case '[':
+		return jsonArrayLiteral(raw)
+	}
+	return string(raw), nil
+}
+
+// jsonArrayLiteral converts a JSON array, possibly nested, into an array
+// literal such as {1,NULL,"a b"}.
+func jsonArrayLiteral(raw json.RawMessage) (string, error) {
+	var elems []json.RawMessage
+	if err := json.Unmarshal(raw, &elems); err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for idx, elem := range elems {
+		if idx > 0 {
+			sb.WriteByte(',')
+		}
+		switch {
+		case string(elem) == "null":
+			sb.WriteString("NULL")
+		case elem[0] == '[':
+			nested, err := jsonArrayLiteral(elem)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(nested)
+		default:
+			text := string(elem)
+			if elem[0] == '"' {
+				if err := json.Unmarshal(elem, &text); err != nil {
+					return "", err
+				}
+			}
+			sb.WriteByte('"')
+			sb.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(text))
+			sb.WriteByte('"')
+		}
+	}
+	sb.WriteByte('}')
+	return sb.String(), nil
+}
@@ -0,0 +1,47 @@
+package pg_mini
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func Test_jsonlCSVReader(t *testing.T) {
+	cols := []columnSchema{
+		{Name: "id", Type: "int4"},
+		{Name: "name", Type: "text"},
+		{Name: "tags", Type: "_text"},
+		{Name: "meta", Type: "jsonb"},
+		{Name: "active", Type: "bool"},
+	}
+	// Key order does not matter, missing keys are NULL
+	src := `{"name":"Ann, \"the\" first","id":1,"tags":["a b",null,"c\"d"],"meta":{"k":[1,2]},"active":true}` + "\n" +
+		"\n" +
+		`{"id":2,"name":"","tags":[[1,2],[3,4]],"meta":null}` + "\n"
+
+	jr, err := newJSONLCSVReader(strings.NewReader(src), cols)
+	if err != nil {
+		t.Fatalf("newJSONLCSVReader: %v", err)
+	}
+	got, err := io.ReadAll(jr)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	want := `"id","name","tags","meta","active"` + "\n" +
+		`"1","Ann, ""the"" first","{""a b"",NULL,""c\""d""}","{""k"":[1,2]}","true"` + "\n" +
+		`"2","","{{""1"",""2""},{""3"",""4""}}",,` + "\n"
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func Test_jsonlCSVReader_UnknownColumn(t *testing.T) {
+	jr, err := newJSONLCSVReader(strings.NewReader(`{"id":1,"nmae":"x"}`+"\n"), []columnSchema{{Name: "id"}, {Name: "name"}})
+	if err != nil {
+		t.Fatalf("newJSONLCSVReader: %v", err)
+	}
+	if _, err := io.ReadAll(jr); err == nil || !strings.Contains(err.Error(), `line 1: unknown column "nmae"`) {
+		t.Errorf("expected unknown column error, got %v", err)
+	}
+}
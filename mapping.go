@@ -0,0 +1,191 @@
+package pg_mini
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Mapping describes how tables in a backup land in a target database whose
+// schema has evolved since the export. It is keyed by the table name in the
+// backup. Tables without an entry are imported unchanged.
+//
+// Mapped tables are always staged: the CSV is copied into a temp table shaped
+// like the backup, then inserted into the target with an INSERT ... SELECT
+// whose projection applies the mapping.
+type Mapping map[string]TableMapping
+
+// TableMapping maps a single backup table onto the target.
+type TableMapping struct {
+	// Target is the table name in the target database. Defaults to the
+	// backup table name.
+	Target string
+	// Columns renames backup columns: backup name -> target name.
+	Columns map[string]string
+	// Drop lists backup columns that are not imported. They can still be
+	// referenced from Set expressions.
+	Drop []string
+	// Set fills target columns from SQL expressions evaluated per row, e.g.
+	// "split_part(fullname, ' ', 1)", "lower(email)", "'unknown'" or "0".
+	// Backup columns are referenced by their backup name. Setting a column
+	// that is also imported from the backup replaces its value.
+	Set map[string]string
+}
+
+// LoadMapping reads a Mapping from a JSON file.
+func LoadMapping(path string) (Mapping, error) {
+	var m Mapping
+	if err := readJSONFile(path, &m); err != nil {
+		return nil, fmt.Errorf("mapping: %w", err)
+	}
+	return m, nil
+}
+
+// validate checks that every mapped table and column exists in the backup.
+func (m Mapping) validate(schema *Schema) error {
+	for tbl, tm := range m {
+		tblSchema, ok := schema.Tables[tbl]
+		if !ok {
+			return fmt.Errorf("mapping: table %s not in backup", tbl)
+		}
+		hasCol := func(name string) bool {
+			return slices.ContainsFunc(tblSchema.Cols, func(c columnSchema) bool { return c.Name == name })
+		}
+		for col := range tm.Columns {
+			if !hasCol(col) {
+				return fmt.Errorf("mapping: column %s.%s not in backup", tbl, col)
+			}
+		}
+		for _, col := range tm.Drop {
+			if !hasCol(col) {
+				return fmt.Errorf("mapping: column %s.%s not in backup", tbl, col)
+			}
+		}
+		for col, expr := range tm.Set {
+			if expr == "" {
+				return fmt.Errorf("mapping: empty expression for %s.%s", tbl, col)
+			}
+		}
+	}
+	return nil
+}
+
+// target returns the target table name for a backup table.
+func (m Mapping) target(tbl string) string {
+	if tm, ok := m[tbl]; ok && tm.Target != "" {
+		return tm.Target
+	}
+	return tbl
+}
+
+// column returns the target column name for a backup column, or "" if the
+// column is dropped.
+func (m Mapping) column(tbl, col string) string {
+	tm := m[tbl]
+	if slices.Contains(tm.Drop, col) {
+		return ""
+	}
+	if to, ok := tm.Columns[col]; ok {
+		return to
+	}
+	return col
+}
+
+// project returns the target columns and the matching SELECT expressions for
+// a backup table whose CSV holds srcCols.
+func (m Mapping) project(tbl string, srcCols []string) (cols, exprs []string) {
+	for _, src := range srcCols {
+		col := m.column(tbl, src)
+		if col == "" {
+			continue
+		}
+		cols = append(cols, col)
+		exprs = append(exprs, src)
+	}
+
+	set := m[tbl].Set
+	setCols := make([]string, 0, len(set))
+	for col := range set {
+		setCols = append(setCols, col)
+	}
+	slices.Sort(setCols)
+
+	for _, col := range setCols {
+		if idx := slices.Index(cols, col); idx >= 0 {
+			exprs[idx] = set[col]
+			continue
+		}
+		cols = append(cols, col)
+		exprs = append(exprs, set[col])
+	}
+	return cols, exprs
+}
+
+// apply returns schema as it will look in the target once the mapping is
+// applied: tables and columns renamed, dropped columns removed and Set
+// columns added. Constraints on dropped columns are removed.
+func (m Mapping) apply(schema *Schema) *Schema {
+	if len(m) == 0 {
+		return schema
+	}
+
+	mapped := &Schema{Tables: make(map[string]tableSchema)}
+	for name, tbl := range schema.Tables {
+		if _, ok := m[name]; !ok {
+			mapped.Tables[name] = tbl
+			continue
+		}
+
+		var srcCols []string
+		types := map[string]string{}
+		for _, col := range tbl.Cols {
+			if col.Generated {
+				continue
+			}
+			srcCols = append(srcCols, col.Name)
+			types[col.Name] = col.Type
+		}
+
+		target := m.target(name)
+		mt := tableSchema{Name: target}
+		cols, exprs := m.project(name, srcCols)
+		for idx, col := range cols {
+			// Only a plain column reference keeps its type; expressions are unknown.
+			mt.Cols = append(mt.Cols, columnSchema{Name: col, Type: types[exprs[idx]]})
+		}
+
+		mt.PrimaryKeyCols = m.columns(name, tbl.PrimaryKeyCols)
+		for _, uc := range tbl.UniqueConstraints {
+			if mc := m.columns(name, uc); mc != nil {
+				mt.UniqueConstraints = append(mt.UniqueConstraints, mc)
+			}
+		}
+		mapped.Tables[target] = mt
+	}
+
+	for _, rel := range schema.Relations {
+		rel.FromColumn = m.column(rel.FromTable, rel.FromColumn)
+		rel.ToColumn = m.column(rel.ToTable, rel.ToColumn)
+		if rel.FromColumn == "" || rel.ToColumn == "" {
+			continue
+		}
+		rel.FromTable = m.target(rel.FromTable)
+		rel.ToTable = m.target(rel.ToTable)
+		mapped.Relations = append(mapped.Relations, rel)
+	}
+
+	return mapped
+}
+
+// columns maps a list of backup columns to target names. It returns nil if
+// any column is dropped.
+func (m Mapping) columns(tbl string, cols []string) []string {
+	var result []string
+	for _, col := range cols {
+		to := m.column(tbl, col)
+		if to == "" {
+			return nil
+		}
+		result = append(result, to)
+	}
+	return result
+}
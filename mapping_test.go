@@ -0,0 +1,136 @@
+package pg_mini
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func Test_generateImportQueries_Mapping(t *testing.T) {
+	backup, _ := driftTestSchemas()
+
+	graph, err := buildGraph(backup, "account")
+	if err != nil {
+		t.Fatalf("buildGraph: %v", err)
+	}
+
+	mapping := Mapping{
+		"account": {
+			Target:  "org",
+			Columns: map[string]string{"score": "rating"},
+			Drop:    []string{"fullname"},
+			Set: map[string]string{
+				"first_name": "split_part(fullname, ' ', 1)",
+				"last_name":  "split_part(fullname, ' ', 2)",
+				"source":     "'import'",
+			},
+		},
+		"invoice": {
+			Columns: map[string]string{"account_id": "org_id"},
+		},
+	}
+	if err := mapping.validate(backup); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	queries := generateImportQueries(graph, backup, importQueryOpts{Mapping: mapping})
+
+	want := []ImportTableQueries{
+		{
+			Table:         "account",
+			Target:        "org",
+			Columns:       []string{"id", "fullname", "score"},
+			File:          "account.csv",
+			Format:        FormatCSV,
+			Truncate:      "TRUNCATE TABLE org CASCADE;",
+			Insert:        "INSERT INTO org (id, rating, first_name, last_name, source) OVERRIDING SYSTEM VALUE SELECT id, score, split_part(fullname, ' ', 1), split_part(fullname, ' ', 2), 'import' FROM (SELECT $1::int4 AS id, $2::text AS fullname, $3::int4 AS score) AS src;",
+			CreateTemp:    "CREATE TEMP TABLE tmp_import_account (id int4, fullname text, score int4);",
+			CopyTemp:      "COPY tmp_import_account (id, fullname, score) FROM STDIN WITH CSV HEADER DELIMITER ',';",
+			InsertTemp:    "INSERT INTO org (id, rating, first_name, last_name, source) OVERRIDING SYSTEM VALUE SELECT id, score, split_part(fullname, ' ', 1), split_part(fullname, ' ', 2), 'import' FROM tmp_import_account;",
+			Upsert:        "INSERT INTO org (id, rating, first_name, last_name, source) SELECT id, score, split_part(fullname, ' ', 1), split_part(fullname, ' ', 2), 'import' FROM tmp_import_account ON CONFLICT (id) DO UPDATE SET rating = EXCLUDED.rating, first_name = EXCLUDED.first_name, last_name = EXCLUDED.last_name, source = EXCLUDED.source;",
+			SoftInsert:    "INSERT INTO org (id, rating, first_name, last_name, source) SELECT id, score, split_part(fullname, ' ', 1), split_part(fullname, ' ', 2), 'import' FROM tmp_import_account ON CONFLICT (id) DO NOTHING;",
+			RowUpsert:     "INSERT INTO org (id, rating, first_name, last_name, source) OVERRIDING SYSTEM VALUE SELECT id, score, split_part(fullname, ' ', 1), split_part(fullname, ' ', 2), 'import' FROM (SELECT $1::int4 AS id, $2::text AS fullname, $3::int4 AS score) AS src ON CONFLICT (id) DO UPDATE SET rating = EXCLUDED.rating, first_name = EXCLUDED.first_name, last_name = EXCLUDED.last_name, source = EXCLUDED.source;",
+			RowSoftInsert: "INSERT INTO org (id, rating, first_name, last_name, source) OVERRIDING SYSTEM VALUE SELECT id, score, split_part(fullname, ' ', 1), split_part(fullname, ' ', 2), 'import' FROM (SELECT $1::int4 AS id, $2::text AS fullname, $3::int4 AS score) AS src ON CONFLICT (id) DO NOTHING;",
+			DropTemp:      "DROP TABLE IF EXISTS tmp_import_account;",
+		},
+		{
+			Table:         "invoice",
+			Target:        "invoice",
+			Columns:       []string{"id", "account_id"},
+			File:          "invoice.csv",
+			Format:        FormatCSV,
+			Truncate:      "TRUNCATE TABLE invoice CASCADE;",
+			Insert:        "INSERT INTO invoice (id, org_id) OVERRIDING SYSTEM VALUE SELECT id, account_id FROM (SELECT $1::int4 AS id, $2::int4 AS account_id) AS src;",
+			CreateTemp:    "CREATE TEMP TABLE tmp_import_invoice (id int4, account_id int4);",
+			CopyTemp:      "COPY tmp_import_invoice (id, account_id) FROM STDIN WITH CSV HEADER DELIMITER ',';",
+			InsertTemp:    "INSERT INTO invoice (id, org_id) OVERRIDING SYSTEM VALUE SELECT id, account_id FROM tmp_import_invoice;",
+			Upsert:        "INSERT INTO invoice (id, org_id) SELECT id, account_id FROM tmp_import_invoice ON CONFLICT (id) DO UPDATE SET org_id = EXCLUDED.org_id;",
+			SoftInsert:    "INSERT INTO invoice (id, org_id) SELECT id, account_id FROM tmp_import_invoice ON CONFLICT (id) DO NOTHING;",
+			RowUpsert:     "INSERT INTO invoice (id, org_id) OVERRIDING SYSTEM VALUE SELECT id, account_id FROM (SELECT $1::int4 AS id, $2::int4 AS account_id) AS src ON CONFLICT (id) DO UPDATE SET org_id = EXCLUDED.org_id;",
+			RowSoftInsert: "INSERT INTO invoice (id, org_id) OVERRIDING SYSTEM VALUE SELECT id, account_id FROM (SELECT $1::int4 AS id, $2::int4 AS account_id) AS src ON CONFLICT (id) DO NOTHING;",
+			DropTemp:      "DROP TABLE IF EXISTS tmp_import_invoice;",
+		},
+	}
+	if d := deep.Equal(queries, want); d != nil {
+		for _, line := range d {
+			t.Error(line)
+		}
+	}
+}
+
+func TestMapping_apply(t *testing.T) {
+	backup, _ := driftTestSchemas()
+
+	mapping := Mapping{
+		"account": {
+			Target:  "org",
+			Columns: map[string]string{"score": "rating"},
+			Drop:    []string{"fullname"},
+			Set:     map[string]string{"first_name": "split_part(fullname, ' ', 1)"},
+		},
+	}
+
+	mapped := mapping.apply(backup)
+
+	if _, ok := mapped.Tables["account"]; ok {
+		t.Error("renamed table should not keep its backup name")
+	}
+	want := tableSchema{
+		Name: "org",
+		Cols: []columnSchema{
+			{Name: "id", Type: "int4"},
+			{Name: "rating", Type: "int4"},
+			{Name: "first_name"},
+		},
+		PrimaryKeyCols: []string{"id"},
+	}
+	if d := deep.Equal(mapped.Tables["org"], want); d != nil {
+		for _, line := range d {
+			t.Error(line)
+		}
+	}
+	if len(mapped.Relations) != 1 || mapped.Relations[0].ToTable != "org" {
+		t.Errorf("relation should point at the renamed table: %+v", mapped.Relations)
+	}
+}
+
+func TestMapping_validate(t *testing.T) {
+	backup, _ := driftTestSchemas()
+
+	tests := []struct {
+		name    string
+		mapping Mapping
+	}{
+		{name: "unknown table", mapping: Mapping{"nope": {}}},
+		{name: "unknown renamed column", mapping: Mapping{"account": {Columns: map[string]string{"nope": "x"}}}},
+		{name: "unknown dropped column", mapping: Mapping{"account": {Drop: []string{"nope"}}}},
+		{name: "empty expression", mapping: Mapping{"account": {Set: map[string]string{"x": ""}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.mapping.validate(backup); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
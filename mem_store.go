@@ -0,0 +1,140 @@
+package pg_mini
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// MemStore is an in-memory Store, for tests and for embedders moving a subset
+// between databases without touching disk. Entries become visible when their
+// writer is closed. It implements ExtendedStore and is safe for concurrent
+// use.
+type MemStore struct {
+	mu       sync.Mutex
+	files    map[string][]byte
+	modTimes map[string]time.Time
+}
+
+// NewMemStore returns an empty MemStore.
+func NewMemStore() *MemStore {
+	return &MemStore{files: map[string][]byte{}, modTimes: map[string]time.Time{}}
+}
+
+func (m *MemStore) Create(name string) (io.WriteCloser, error) {
+	return &memWriter{store: m, name: name}, nil
+}
+
+func (m *MemStore) Open(name string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.files[name]
+	if !ok {
+		return nil, fmt.Errorf("MemStore: open %s: %w", name, fs.ErrNotExist)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// List returns the names of all entries starting with prefix, sorted.
+func (m *MemStore) List(prefix string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var names []string
+	for name := range m.files {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// Stat describes name. ModTime is when its writer was closed.
+func (m *MemStore) Stat(name string) (fs.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.files[name]
+	if !ok {
+		return nil, fmt.Errorf("MemStore: stat %s: %w", name, fs.ErrNotExist)
+	}
+	return memFileInfo{name: name, size: int64(len(data)), modTime: m.modTimes[name]}, nil
+}
+
+// Delete removes name.
+func (m *MemStore) Delete(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.files, name)
+	delete(m.modTimes, name)
+	return nil
+}
+
+// Snapshot returns an independent copy of the store, e.g. to keep an export
+// untouched by the reports a later import writes next to it.
+func (m *MemStore) Snapshot() *MemStore {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	files := make(map[string][]byte, len(m.files))
+	for name, data := range m.files {
+		files[name] = slices.Clone(data)
+	}
+	return &MemStore{files: files, modTimes: maps.Clone(m.modTimes)}
+}
+
+// WriteJSON dumps the store as a JSON object keyed by entry name, handy for
+// inspecting a failing test. Text entries are written as strings; binary
+// entries (binary or parquet formats) as {"Base64": "..."}.
+func (m *MemStore) WriteJSON(w io.Writer) error {
+	m.mu.Lock()
+	dump := make(map[string]any, len(m.files))
+	for name, data := range m.files {
+		if utf8.Valid(data) {
+			dump[name] = string(data)
+		} else {
+			dump[name] = struct{ Base64 []byte }{data}
+		}
+	}
+	m.mu.Unlock()
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(dump)
+}
+
+// memFileInfo describes a MemStore entry.
+type memFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (fi memFileInfo) Name() string       { return fi.name }
+func (fi memFileInfo) Size() int64        { return fi.size }
+func (fi memFileInfo) Mode() fs.FileMode  { return 0o644 }
+func (fi memFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi memFileInfo) IsDir() bool        { return false }
+func (fi memFileInfo) Sys() any           { return nil }
+
+type memWriter struct {
+	store *MemStore
+	name  string
+	buf   bytes.Buffer
+}
+
+func (w *memWriter) Write(p []byte) (int, error) { return w.buf.Write(p) }
+
+func (w *memWriter) Close() error {
+	w.store.mu.Lock()
+	defer w.store.mu.Unlock()
+	w.store.files[w.name] = slices.Clone(w.buf.Bytes())
+	w.store.modTimes[w.name] = time.Now()
+	return nil
+}
@@ -0,0 +1,125 @@
+package pg_mini
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Observer receives progress events from Export and Import. Events are
+// delivered synchronously, in order, from the goroutine running Run, so
+// implementations should return quickly.
+type Observer interface {
+	Event(ev Event)
+}
+
+// ObserverFunc adapts a function to the Observer interface.
+type ObserverFunc func(ev Event)
+
+func (f ObserverFunc) Event(ev Event) { f(ev) }
+
+// EventKind identifies a progress event.
+type EventKind string
+
+const (
+	// EventTableStarted is sent when work on a table begins: selecting its
+	// subset into a temp table on export, loading its file on import.
+	EventTableStarted EventKind = "table_started"
+	// EventTempCopied is sent on export once a table's subset is in its temp
+	// table. Rows and Duration are set.
+	EventTempCopied EventKind = "temp_copied"
+	// EventCSVStarted is sent on export before a temp table is written to
+	// the Store.
+	EventCSVStarted EventKind = "csv_started"
+	// EventBytes reports the bytes written to (export) or read from (import)
+	// the Store for a table so far. Sent about every progressInterval bytes.
+	EventBytes EventKind = "bytes"
+	// EventRowFailed is sent for every row rejected with SkipErrors. Line and
+	// Error are set.
+	EventRowFailed EventKind = "row_failed"
+	// EventTableDone is sent when a table is finished. Rows, Bytes and
+	// Duration are set.
+	EventTableDone EventKind = "table_done"
+	// EventFinished is sent once the run ends, successfully or not. Rows and
+	// Bytes are totals, Error is set on failure.
+	EventFinished EventKind = "finished"
+)
+
+// Event is a single progress event. Fields that do not apply to the Kind are
+// left zero.
+type Event struct {
+	Kind     EventKind
+	Op       string // export or import
+	Table    string
+	Rows     int64
+	Bytes    int64
+	Line     int64 // CSV line of a failed row
+	Error    string
+	Duration time.Duration
+	Time     time.Time
+}
+
+// progressInterval is how many bytes pass between EventBytes events.
+const progressInterval = 1 << 20
+
+// JSONLinesObserver writes each event as one line of JSON to w. It is safe
+// to share between concurrent runs.
+func JSONLinesObserver(w io.Writer) Observer {
+	return &jsonLinesObserver{enc: json.NewEncoder(w)}
+}
+
+type jsonLinesObserver struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func (o *jsonLinesObserver) Event(ev Event) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	_ = o.enc.Encode(ev)
+}
+
+// ChanObserver sends every event to ch. Sends block, so the receiver must
+// keep draining ch until Run returns.
+func ChanObserver(ch chan<- Event) Observer {
+	return ObserverFunc(func(ev Event) { ch <- ev })
+}
+
+// multiObserver fans events out to several observers, skipping nil ones.
+type multiObserver []Observer
+
+func (m multiObserver) Event(ev Event) {
+	for _, o := range m {
+		if o != nil {
+			o.Event(ev)
+		}
+	}
+}
+
+// notifier stamps and forwards events for one run. The zero value drops
+// events.
+type notifier struct {
+	op  string
+	obs Observer
+}
+
+func (n notifier) send(ev Event) {
+	if n.obs == nil {
+		return
+	}
+	ev.Op = n.op
+	ev.Time = time.Now()
+	n.obs.Event(ev)
+}
+
+// progress returns the callback counting readers and writers use to report
+// bytes for tbl, or nil when nobody is listening.
+func (n notifier) progress(tbl string) func(int64) {
+	if n.obs == nil {
+		return nil
+	}
+	return func(bytes int64) {
+		n.send(Event{Kind: EventBytes, Table: tbl, Bytes: bytes})
+	}
+}
@@ -0,0 +1,62 @@
+package pg_mini
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+)
+
+func TestJSONLinesObserver(t *testing.T) {
+	var buf bytes.Buffer
+	events := notifier{op: "export", obs: JSONLinesObserver(&buf)}
+	events.send(Event{Kind: EventTableStarted, Table: "company"})
+	events.send(Event{Kind: EventTableDone, Table: "company", Rows: 3, Bytes: 42})
+
+	dec := json.NewDecoder(&buf)
+	var got []Event
+	for {
+		var ev Event
+		if err := dec.Decode(&ev); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		got = append(got, ev)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d events, want 2", len(got))
+	}
+	if got[1].Kind != EventTableDone || got[1].Op != "export" || got[1].Rows != 3 || got[1].Time.IsZero() {
+		t.Errorf("unexpected event: %+v", got[1])
+	}
+}
+
+func TestCountingWriter_progress(t *testing.T) {
+	var reports []int64
+	cw := &countingWriter{w: io.Discard, progress: func(n int64) { reports = append(reports, n) }}
+
+	chunk := make([]byte, progressInterval/2)
+	for range 5 {
+		cw.Write(chunk)
+	}
+	want := []int64{progressInterval, 2 * progressInterval}
+	if len(reports) != len(want) || reports[0] != want[0] || reports[1] != want[1] {
+		t.Errorf("progress reports = %v, want %v", reports, want)
+	}
+}
+
+func TestGraphPrinter_Event(t *testing.T) {
+	graph := &Graph{Tables: map[string]*Table{"company": {Name: "company"}}}
+	printer := &GraphPrinter{g: graph}
+
+	printer.Event(Event{Op: "export", Kind: EventTempCopied, Table: "company", Rows: 7})
+	if tbl := graph.Tables["company"]; tbl.status != statusCopyDone || tbl.rows != 7 {
+		t.Errorf("unexpected table state: %+v", tbl)
+	}
+
+	printer.Event(Event{Op: "import", Kind: EventTableDone, Table: "company"})
+	if graph.Tables["company"].status != statusCopyDone {
+		t.Errorf("import events should not change the export status")
+	}
+}
@@ -0,0 +1,437 @@
+package pg_mini
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetRowGroupSize is the number of rows written per Parquet row group.
+const parquetRowGroupSize = 100_000
+
+// parquetColumnsKey is the key-value metadata entry holding the table's
+// column order as a JSON array. Parquet schemas order columns by name, COPY
+// needs them in table order.
+const parquetColumnsKey = "pg_mini.columns"
+
+// parquetNode returns the Parquet type for a PostgreSQL type name and whether
+// values must be selected as text. Types without a lossless Parquet
+// counterpart (numeric, uuid, arrays, intervals, enums, ...) are stored as
+// their PostgreSQL text representation.
+func parquetNode(pgType string) (node parquet.Node, asText bool) {
+	switch pgType {
+	case "bool":
+		return parquet.Leaf(parquet.BooleanType), false
+	case "int2":
+		return parquet.Int(16), false
+	case "int4":
+		return parquet.Int(32), false
+	case "int8":
+		return parquet.Int(64), false
+	case "float4":
+		return parquet.Leaf(parquet.FloatType), false
+	case "float8":
+		return parquet.Leaf(parquet.DoubleType), false
+	case "bytea":
+		return parquet.Leaf(parquet.ByteArrayType), false
+	case "date":
+		return parquet.Date(), false
+	case "timestamp":
+		return parquet.TimestampAdjusted(parquet.Microsecond, false), false
+	case "timestamptz":
+		return parquet.Timestamp(parquet.Microsecond), false
+	case "json", "jsonb":
+		return parquet.JSON(), true
+	default:
+		return parquet.String(), true
+	}
+}
+
+// parquetTableWriter writes rows decoded by pgx to a Parquet file.
+type parquetTableWriter struct {
+	pw    *parquet.Writer
+	cols  []parquetCol
+	batch []parquet.Row
+}
+
+// parquetCol is a column and where its values go in a Parquet row.
+type parquetCol struct {
+	name  string
+	index int // leaf column index
+	date  bool
+}
+
+// newParquetTableWriter writes a table with the given column names and
+// PostgreSQL types to w. Values must be selected as text where parquetNode
+// says so.
+func newParquetTableWriter(w io.Writer, tbl string, names, types []string) *parquetTableWriter {
+	group := parquet.Group{}
+	for idx, col := range names {
+		node, _ := parquetNode(types[idx])
+		group[col] = parquet.Optional(node)
+	}
+	schema := parquet.NewSchema(tbl, group)
+
+	tw := &parquetTableWriter{batch: make([]parquet.Row, 0, 1024)}
+	for idx, col := range names {
+		leaf, _ := schema.Lookup(col)
+		tw.cols = append(tw.cols, parquetCol{name: col, index: leaf.ColumnIndex, date: types[idx] == "date"})
+	}
+	order, _ := json.Marshal(names)
+	tw.pw = parquet.NewWriter(w, schema,
+		parquet.Compression(&parquet.Zstd),
+		parquet.MaxRowsPerRowGroup(parquetRowGroupSize),
+		parquet.KeyValueMetadata(parquetColumnsKey, string(order)),
+	)
+	return tw
+}
+
+// write adds a row, one value per column.
+func (tw *parquetTableWriter) write(vals []any) error {
+	row := make(parquet.Row, len(tw.cols))
+	for idx, col := range tw.cols {
+		pv, err := parquetValue(vals[idx], col.date)
+		if err != nil {
+			return fmt.Errorf("column %s: %w", col.name, err)
+		}
+		def := 1
+		if pv.IsNull() {
+			def = 0
+		}
+		row[col.index] = pv.Level(0, def, col.index)
+	}
+	tw.batch = append(tw.batch, row)
+
+	if len(tw.batch) == cap(tw.batch) {
+		return tw.flush()
+	}
+	return nil
+}
+
+func (tw *parquetTableWriter) flush() error {
+	if _, err := tw.pw.WriteRows(tw.batch); err != nil {
+		return fmt.Errorf("writing rows: %w", err)
+	}
+	tw.batch = tw.batch[:0]
+	return nil
+}
+
+// close writes the remaining rows and the footer.
+func (tw *parquetTableWriter) close() error {
+	if err := tw.flush(); err != nil {
+		return err
+	}
+	if err := tw.pw.Close(); err != nil {
+		return fmt.Errorf("writing parquet footer: %w", err)
+	}
+	return nil
+}
+
+// copyToParquet runs query (a SELECT over a temp table) and writes the rows
+// to name as Parquet. Column types come from the introspected schema, or the
+// result set for columns the schema does not know (e.g. raw queries).
+func copyToParquet(ctx context.Context, conn *pgx.Conn, store Store, name, query string, tbl tableSchema, progress func(int64)) (*copyOutRes, error) {
+	query = strings.TrimSuffix(query, ";")
+
+	desc, err := conn.PgConn().Prepare(ctx, "", query, nil)
+	if err != nil {
+		return nil, fmt.Errorf("describing query: %w", err)
+	}
+
+	colTypes := map[string]string{}
+	for _, col := range tbl.Cols {
+		colTypes[col.Name] = col.Type
+	}
+
+	var names, types, exprs []string
+	for _, fd := range desc.Fields {
+		colType, ok := colTypes[fd.Name]
+		if !ok {
+			if t, ok := conn.TypeMap().TypeForOID(fd.DataTypeOID); ok {
+				colType = t.Name
+			}
+		}
+		names = append(names, fd.Name)
+		types = append(types, colType)
+		if _, asText := parquetNode(colType); asText {
+			exprs = append(exprs, fmt.Sprintf("%s::text AS %s", fd.Name, fd.Name))
+		} else {
+			exprs = append(exprs, fd.Name)
+		}
+	}
+
+	f, err := store.Create(name)
+	if err != nil {
+		return nil, fmt.Errorf("creating file: %w", err)
+	}
+	closed := false
+	defer func() {
+		if !closed {
+			f.Close()
+		}
+	}()
+
+	cw := &countingWriter{w: f, progress: progress}
+	tw := newParquetTableWriter(cw, tbl.Name, names, types)
+
+	queryStart := time.Now()
+	rows, err := conn.Query(ctx, fmt.Sprintf("SELECT %s FROM (%s) AS src", strings.Join(exprs, ", "), query))
+	if err != nil {
+		return nil, fmt.Errorf("selecting rows: %w", err)
+	}
+	defer rows.Close()
+
+	var count int64
+	for rows.Next() {
+		vals, err := rows.Values()
+		if err != nil {
+			return nil, fmt.Errorf("reading row: %w", err)
+		}
+		if err := tw.write(vals); err != nil {
+			return nil, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("selecting rows: %w", err)
+	}
+	if err := tw.close(); err != nil {
+		return nil, err
+	}
+	duration := time.Since(queryStart)
+
+	closed = true
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("closing file: %w", err)
+	}
+
+	return &copyOutRes{
+		FileName: name,
+		Rows:     count,
+		Duration: duration,
+		FileSize: cw.n,
+	}, nil
+}
+
+// parquetValue converts a value decoded by pgx into a Parquet value.
+func parquetValue(v any, date bool) (parquet.Value, error) {
+	switch v := v.(type) {
+	case nil:
+		return parquet.NullValue(), nil
+	case bool:
+		return parquet.BooleanValue(v), nil
+	case int16:
+		return parquet.Int32Value(int32(v)), nil
+	case int32:
+		return parquet.Int32Value(v), nil
+	case int64:
+		return parquet.Int64Value(v), nil
+	case float32:
+		return parquet.FloatValue(v), nil
+	case float64:
+		return parquet.DoubleValue(v), nil
+	case []byte:
+		return parquet.ByteArrayValue(v), nil
+	case string:
+		return parquet.ByteArrayValue([]byte(v)), nil
+	case time.Time:
+		if date {
+			return parquet.Int32Value(int32(v.Unix() / 86400)), nil
+		}
+		return parquet.Int64Value(v.UnixMicro()), nil
+	}
+	return parquet.Value{}, fmt.Errorf("unsupported value %T (infinite dates and timestamps cannot be stored in parquet)", v)
+}
+
+// parquetCSVReader renders a Parquet file as CSV, the way
+// COPY ... (FORMAT csv, HEADER, FORCE_QUOTE *) writes it, so the CSV import
+// paths can load Parquet exports. Parquet needs random access, so the source
+// is spooled to a temp file first.
+type parquetCSVReader struct {
+	spool *os.File
+	rows  *parquet.Reader
+	cols  []parquet.LeafColumn // in CSV order
+	batch []parquet.Row
+	buf   bytes.Buffer
+	w     *bufio.Writer
+	err   error
+}
+
+func newParquetCSVReader(src io.Reader) (*parquetCSVReader, error) {
+	spool, err := os.CreateTemp("", "pg_mini-*.parquet")
+	if err != nil {
+		return nil, fmt.Errorf("create spool file: %w", err)
+	}
+	pr := &parquetCSVReader{spool: spool}
+
+	size, err := io.Copy(spool, src)
+	if err != nil {
+		pr.Close()
+		return nil, fmt.Errorf("spooling parquet file: %w", err)
+	}
+	file, err := parquet.OpenFile(spool, size)
+	if err != nil {
+		pr.Close()
+		return nil, fmt.Errorf("opening parquet file: %w", err)
+	}
+
+	// Files written elsewhere have no column order, keep the schema's
+	var names []string
+	var paths [][]string
+	if order, ok := file.Lookup(parquetColumnsKey); ok {
+		if err := json.Unmarshal([]byte(order), &names); err != nil {
+			pr.Close()
+			return nil, fmt.Errorf("parse %s metadata: %w", parquetColumnsKey, err)
+		}
+		for _, col := range names {
+			paths = append(paths, []string{col})
+		}
+	} else {
+		paths = file.Schema().Columns()
+		for _, path := range paths {
+			names = append(names, strings.Join(path, "."))
+		}
+	}
+	for idx, path := range paths {
+		leaf, ok := file.Schema().Lookup(path...)
+		if !ok {
+			pr.Close()
+			return nil, fmt.Errorf("parquet file missing column %q", names[idx])
+		}
+		pr.cols = append(pr.cols, leaf)
+	}
+
+	pr.rows = parquet.NewReader(file)
+	pr.batch = make([]parquet.Row, 1024)
+	pr.w = bufio.NewWriter(&pr.buf)
+	if err := writeCSVRecord(pr.w, names, nil); err != nil {
+		pr.Close()
+		return nil, err
+	}
+	return pr, nil
+}
+
+func (pr *parquetCSVReader) Read(p []byte) (int, error) {
+	for pr.buf.Len() == 0 && pr.err == nil {
+		pr.err = pr.fill()
+	}
+	if pr.buf.Len() > 0 {
+		return pr.buf.Read(p)
+	}
+	return 0, pr.err
+}
+
+// fill renders the next batch of rows into buf, returning io.EOF after the
+// last one.
+func (pr *parquetCSVReader) fill() error {
+	n, err := pr.rows.ReadRows(pr.batch)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("reading parquet rows: %w", err)
+	}
+
+	record := make([]string, len(pr.cols))
+	nulls := make([]bool, len(pr.cols))
+	vals := make([]parquet.Value, len(pr.rows.Schema().Columns()))
+	for _, row := range pr.batch[:n] {
+		for _, v := range row {
+			vals[v.Column()] = v
+		}
+		for idx, col := range pr.cols {
+			text, null, verr := parquetText(vals[col.ColumnIndex], col.Node)
+			if verr != nil {
+				return fmt.Errorf("column %s: %w", strings.Join(col.Path, "."), verr)
+			}
+			record[idx], nulls[idx] = text, null
+		}
+		if werr := writeCSVRecord(pr.w, record, nulls); werr != nil {
+			return werr
+		}
+	}
+	if ferr := pr.w.Flush(); ferr != nil {
+		return ferr
+	}
+	return err
+}
+
+func (pr *parquetCSVReader) Close() error {
+	if pr.rows != nil {
+		pr.rows.Close()
+	}
+	pr.spool.Close()
+	return os.Remove(pr.spool.Name())
+}
+
+// parquetText renders a Parquet value in PostgreSQL's text input format.
+func parquetText(v parquet.Value, node parquet.Node) (text string, null bool, err error) {
+	if v.IsNull() {
+		return "", true, nil
+	}
+
+	logical := node.Type().LogicalType()
+	switch {
+	case logical != nil && logical.Date != nil:
+		return time.Unix(int64(v.Int32())*86400, 0).UTC().Format("2006-01-02"), false, nil
+	case logical != nil && logical.Timestamp != nil:
+		var t time.Time
+		switch unit := logical.Timestamp.Unit; {
+		case unit.Millis != nil:
+			t = time.UnixMilli(v.Int64())
+		case unit.Nanos != nil:
+			t = time.Unix(0, v.Int64())
+		default:
+			t = time.UnixMicro(v.Int64())
+		}
+		text = t.UTC().Format("2006-01-02 15:04:05.999999")
+		if logical.Timestamp.IsAdjustedToUTC {
+			text += "+00"
+		}
+		return text, false, nil
+	}
+
+	switch v.Kind() {
+	case parquet.Boolean:
+		if v.Boolean() {
+			return "t", false, nil
+		}
+		return "f", false, nil
+	case parquet.Int32:
+		return strconv.FormatInt(int64(v.Int32()), 10), false, nil
+	case parquet.Int64:
+		return strconv.FormatInt(v.Int64(), 10), false, nil
+	case parquet.Float:
+		return formatFloat(float64(v.Float()), 32), false, nil
+	case parquet.Double:
+		return formatFloat(v.Double(), 64), false, nil
+	case parquet.ByteArray, parquet.FixedLenByteArray:
+		if logical != nil && (logical.UTF8 != nil || logical.Json != nil || logical.Enum != nil) {
+			return string(v.ByteArray()), false, nil
+		}
+		return `\x` + hex.EncodeToString(v.ByteArray()), false, nil
+	}
+	return "", false, fmt.Errorf("unsupported parquet type %s", node.Type())
+}
+
+// formatFloat renders f so PostgreSQL parses back the exact same value.
+func formatFloat(f float64, bitSize int) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return strconv.FormatFloat(f, 'g', -1, bitSize)
+}
@@ -0,0 +1,266 @@
+// Package pg_minitest helps integration tests use pg_mini fixtures. RoundTrip
+// exports a subset from one database, imports it into another and diffs the
+// subset on both sides, table by table.
+package pg_minitest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/fritzkeyzer/pg_mini"
+	"github.com/jackc/pgx/v5"
+)
+
+// Options configures a round trip. RootTable, Filter and RawQuery select the
+// subset as for pg_mini.Export.
+type Options struct {
+	RootTable string
+	Filter    string
+	RawQuery  string
+
+	// Import, if set, adjusts the import before it runs, e.g. to set
+	// Truncate, Upsert or a Mapping.
+	Import func(imp *pg_mini.Import)
+}
+
+// Result holds the outcome of a round trip.
+type Result struct {
+	// Source is the export of the source database, Target the export of the
+	// same subset from the target after the import.
+	Source *pg_mini.MemStore
+	Target *pg_mini.MemStore
+
+	// Diffs lists the tables whose rows differ, in export order.
+	Diffs []TableDiff
+}
+
+// TableDiff lists the rows of one table that differ between source and
+// target. Rows are JSON objects keyed by column name, with the keys sorted.
+type TableDiff struct {
+	Table   string
+	Missing []string // in the source subset but not the target's
+	Extra   []string // in the target subset but not the source's
+}
+
+// Equal reports whether the subset is identical on both sides.
+func (r *Result) Equal() bool {
+	return len(r.Diffs) == 0
+}
+
+// String describes the differences, one line per row.
+func (r *Result) String() string {
+	if r.Equal() {
+		return "no differences"
+	}
+	var sb strings.Builder
+	for _, d := range r.Diffs {
+		fmt.Fprintf(&sb, "table %s: %d missing, %d extra\n", d.Table, len(d.Missing), len(d.Extra))
+		for _, row := range d.Missing {
+			fmt.Fprintf(&sb, "  - %s\n", row)
+		}
+		for _, row := range d.Extra {
+			fmt.Fprintf(&sb, "  + %s\n", row)
+		}
+	}
+	return sb.String()
+}
+
+// RoundTrip exports the subset selected by opts from src into a MemStore,
+// imports it into dst, then exports the same subset from dst and compares
+// both exports row by row. Row and column order are ignored.
+//
+// The tmp_mini_* temp tables of each export are dropped afterwards, so both
+// connections can be reused; other temp tables of the session are left
+// alone. Clone imports assign new keys and do not round-trip.
+func RoundTrip(ctx context.Context, src, dst *pgx.Conn, opts Options) (*Result, error) {
+	source, sourceReport, err := export(ctx, src, opts)
+	if err != nil {
+		return nil, fmt.Errorf("export source: %w", err)
+	}
+
+	imp := &pg_mini.Import{
+		DB:           dst,
+		RootTable:    opts.RootTable,
+		Store:        source.Snapshot(),
+		NoAnimations: true,
+	}
+	if opts.Import != nil {
+		opts.Import(imp)
+	}
+	if err := imp.Run(ctx); err != nil {
+		return nil, fmt.Errorf("import: %w", err)
+	}
+
+	target, targetReport, err := export(ctx, dst, opts)
+	if err != nil {
+		return nil, fmt.Errorf("export target: %w", err)
+	}
+
+	diffs, err := diffExports(source, sourceReport, target, targetReport)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Source: source, Target: target, Diffs: diffs}, nil
+}
+
+// AssertRoundTrip runs RoundTrip and fails t if it errors or the subset
+// differs between source and target.
+func AssertRoundTrip(t testing.TB, ctx context.Context, src, dst *pgx.Conn, opts Options) *Result {
+	t.Helper()
+	res, err := RoundTrip(ctx, src, dst, opts)
+	if err != nil {
+		t.Fatalf("round trip %s: %v", opts.RootTable, err)
+	}
+	if !res.Equal() {
+		t.Fatalf("round trip %s differs:\n%s", opts.RootTable, res)
+	}
+	return res
+}
+
+// export writes the subset as JSON Lines, one object per row, so rows can be
+// compared as strings with NULLs and embedded newlines kept intact.
+func export(ctx context.Context, conn *pgx.Conn, opts Options) (*pg_mini.MemStore, *pg_mini.ExportReport, error) {
+	store := pg_mini.NewMemStore()
+	exp := &pg_mini.Export{
+		DB:           conn,
+		RootTable:    opts.RootTable,
+		Filter:       opts.Filter,
+		RawQuery:     opts.RawQuery,
+		Store:        store,
+		Format:       pg_mini.FormatJSONL,
+		NoAnimations: true,
+	}
+	report, err := exp.RunWithReport(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := dropExportTables(ctx, conn); err != nil {
+		return nil, nil, fmt.Errorf("drop export temp tables: %w", err)
+	}
+	return store, report, nil
+}
+
+// dropExportTables drops the tmp_mini_* temp tables an export leaves behind.
+func dropExportTables(ctx context.Context, conn *pgx.Conn) error {
+	rows, err := conn.Query(ctx, `SELECT relname FROM pg_class WHERE relnamespace = pg_my_temp_schema() AND relkind = 'r' AND relname LIKE 'tmp\_mini\_%'`)
+	if err != nil {
+		return err
+	}
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	tables := make([]string, len(names))
+	for idx, name := range names {
+		tables[idx] = pgx.Identifier{"pg_temp", name}.Sanitize()
+	}
+	_, err = conn.Exec(ctx, fmt.Sprintf("DROP TABLE %s;", strings.Join(tables, ", ")))
+	return err
+}
+
+// diffExports compares the table files of two exports of the same subset.
+func diffExports(source *pg_mini.MemStore, sourceReport *pg_mini.ExportReport, target *pg_mini.MemStore, targetReport *pg_mini.ExportReport) ([]TableDiff, error) {
+	targetFiles := map[string]string{}
+	for _, tbl := range targetReport.Tables {
+		targetFiles[tbl.Table] = tbl.File
+	}
+
+	var diffs []TableDiff
+	for _, tbl := range sourceReport.Tables {
+		want, err := readRows(source, tbl.File)
+		if err != nil {
+			return nil, err
+		}
+		var got []string
+		if file, ok := targetFiles[tbl.Table]; ok {
+			if got, err = readRows(target, file); err != nil {
+				return nil, err
+			}
+		}
+		if d := diffRows(tbl.Table, want, got); len(d.Missing) > 0 || len(d.Extra) > 0 {
+			diffs = append(diffs, d)
+		}
+	}
+	return diffs, nil
+}
+
+func readRows(store *pg_mini.MemStore, name string) ([]string, error) {
+	f, err := store.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rows []string
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 1024*1024), 256*1024*1024)
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		row, err := normalizeRow(line)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", name, err)
+		}
+		rows = append(rows, row)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", name, err)
+	}
+	return rows, nil
+}
+
+// normalizeRow re-encodes a JSON row with its keys sorted, so rows compare
+// equal whatever the column order of the table on either side. Numbers are
+// kept as written.
+func normalizeRow(line []byte) (string, error) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	var row map[string]any
+	if err := dec.Decode(&row); err != nil {
+		return "", fmt.Errorf("decode row: %w", err)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(row); err != nil {
+		return "", fmt.Errorf("encode row: %w", err)
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// diffRows compares two multisets of rows.
+func diffRows(table string, want, got []string) TableDiff {
+	counts := map[string]int{}
+	for _, row := range want {
+		counts[row]++
+	}
+	d := TableDiff{Table: table}
+	for _, row := range got {
+		if counts[row] > 0 {
+			counts[row]--
+			continue
+		}
+		d.Extra = append(d.Extra, row)
+	}
+	for _, row := range want {
+		if counts[row] > 0 {
+			counts[row]--
+			d.Missing = append(d.Missing, row)
+		}
+	}
+	slices.Sort(d.Missing)
+	slices.Sort(d.Extra)
+	return d
+}
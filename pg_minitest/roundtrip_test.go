@@ -0,0 +1,93 @@
+package pg_minitest
+
+import (
+	"io"
+	"testing"
+
+	"github.com/fritzkeyzer/pg_mini"
+	"github.com/go-test/deep"
+)
+
+func memStore(t *testing.T, files map[string]string) *pg_mini.MemStore {
+	t.Helper()
+	s := pg_mini.NewMemStore()
+	for name, data := range files {
+		w, err := s.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, data)
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func Test_diffExports(t *testing.T) {
+	source := memStore(t, map[string]string{
+		"company.jsonl": `{"id":1,"name":"Acme"}` + "\n" + `{"id":2,"name":null}` + "\n",
+		"website.jsonl": `{"id":10,"company_id":1}` + "\n" + `{"id":10,"company_id":1}` + "\n",
+		"tag.jsonl":     "",
+	})
+	sourceReport := &pg_mini.ExportReport{Tables: []pg_mini.ExportTableReport{
+		{Table: "company", File: "company.jsonl"},
+		{Table: "website", File: "website.jsonl"},
+		{Table: "tag", File: "tag.jsonl"},
+	}}
+	target := memStore(t, map[string]string{
+		"company.jsonl": `{"id":2,"name":""}` + "\n" + `{"name":"Acme","id":1}` + "\n",
+		"website.jsonl": `{"company_id":1,"id":10}` + "\n",
+		"tag.jsonl":     "\n",
+	})
+	targetReport := &pg_mini.ExportReport{Tables: []pg_mini.ExportTableReport{
+		{Table: "company", File: "company.jsonl"},
+		{Table: "website", File: "website.jsonl"},
+		{Table: "tag", File: "tag.jsonl"},
+	}}
+
+	got, err := diffExports(source, sourceReport, target, targetReport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []TableDiff{
+		{Table: "company", Missing: []string{`{"id":2,"name":null}`}, Extra: []string{`{"id":2,"name":""}`}},
+		{Table: "website", Missing: []string{`{"company_id":1,"id":10}`}},
+	}
+	if diff := deep.Equal(got, want); diff != nil {
+		t.Error(diff)
+	}
+
+	res := &Result{Diffs: got}
+	wantStr := `table company: 1 missing, 1 extra
+  - {"id":2,"name":null}
+  + {"id":2,"name":""}
+table website: 1 missing, 0 extra
+  - {"company_id":1,"id":10}
+`
+	if res.String() != wantStr {
+		t.Errorf("got\n%s\nwant\n%s", res, wantStr)
+	}
+}
+
+func Test_normalizeRow(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{in: `{"name":"A & B","id":1}`, want: `{"id":1,"name":"A & B"}`},
+		{in: `{"price":12.50,"big":9007199254740993}`, want: `{"big":9007199254740993,"price":12.50}`},
+		{in: `{"doc":{"b":1,"a":null},"id":2}`, want: `{"doc":{"a":null,"b":1},"id":2}`},
+	}
+	for _, tt := range tests {
+		got, err := normalizeRow([]byte(tt.in))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.in, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.in, got, tt.want)
+		}
+	}
+	if _, err := normalizeRow([]byte("id,name")); err == nil {
+		t.Error("expected an error for a non-JSON row")
+	}
+}
@@ -21,7 +21,14 @@ type tableSchema struct {
 
 type columnSchema struct {
 	Name      string
+	Type      string // udt_name, e.g. "int4", "varchar", "_text"; domains resolve to their base type
+	FullType  string // format_type, e.g. "character varying(64)", "numeric(10,2)", "integer[]"
+	Nullable  bool
+	Default   string // default expression, empty if none
+	Identity  string // "always", "by default" or empty
 	Generated bool
+	Collation string // collation when it differs from the type's default, e.g. "C"
+	ArrayDims int    // declared array dimensions, 0 for non-array columns
 }
 
 type foreignKeyRelation struct {
@@ -78,13 +85,28 @@ func queryDBSchema(ctx context.Context, db *pgx.Conn) (*Schema, error) {
 func getTables(ctx context.Context, conn *pgx.Conn) ([]tableSchema, error) {
 	query := `
 		SELECT
-			t.table_name,
-			c.column_name,
-			CASE WHEN c.generation_expression != '' THEN true ELSE false END as is_generated
-		FROM information_schema.tables t
-			 JOIN information_schema.columns c ON c.table_name = t.table_name
-		WHERE t.table_schema = 'public' and c.table_schema = 'public'
-		ORDER BY t.table_name, c.ordinal_position;
+			c.relname,
+			a.attname,
+			COALESCE(bt.typname, t.typname),
+			format_type(a.atttypid, a.atttypmod),
+			NOT a.attnotnull,
+			CASE WHEN a.attgenerated = '' THEN COALESCE(pg_get_expr(d.adbin, d.adrelid), '') ELSE '' END,
+			a.attidentity::text,
+			a.attgenerated <> '',
+			COALESCE(co.collname, ''),
+			a.attndims
+		FROM pg_attribute a
+			JOIN pg_class c ON c.oid = a.attrelid
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+			JOIN pg_type t ON t.oid = a.atttypid
+			LEFT JOIN pg_type bt ON t.typtype = 'd' AND bt.oid = t.typbasetype
+			LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+			LEFT JOIN pg_collation co ON co.oid = a.attcollation AND a.attcollation <> t.typcollation
+		WHERE n.nspname = 'public'
+			AND c.relkind IN ('r', 'p', 'v', 'f')
+			AND a.attnum > 0
+			AND NOT a.attisdropped
+		ORDER BY c.relname, a.attnum;
 	`
 
 	rows, err := conn.Query(ctx, query)
@@ -95,12 +117,22 @@ func getTables(ctx context.Context, conn *pgx.Conn) ([]tableSchema, error) {
 
 	tables := make(map[string]*tableSchema)
 	for rows.Next() {
-		var tableName, colName string
-		var isGenerated bool
+		var tableName string
+		var col columnSchema
+		var identity string
+		var arrayDims int32
 
-		if err := rows.Scan(&tableName, &colName, &isGenerated); err != nil {
+		if err := rows.Scan(&tableName, &col.Name, &col.Type, &col.FullType, &col.Nullable, &col.Default,
+			&identity, &col.Generated, &col.Collation, &arrayDims); err != nil {
 			return nil, fmt.Errorf("scanning row: %w", err)
 		}
+		switch identity {
+		case "a":
+			col.Identity = "always"
+		case "d":
+			col.Identity = "by default"
+		}
+		col.ArrayDims = int(arrayDims)
 
 		if _, exists := tables[tableName]; !exists {
 			tables[tableName] = &tableSchema{
@@ -108,10 +140,10 @@ func getTables(ctx context.Context, conn *pgx.Conn) ([]tableSchema, error) {
 			}
 		}
 
-		tables[tableName].Cols = append(tables[tableName].Cols, columnSchema{
-			Name:      colName,
-			Generated: isGenerated,
-		})
+		tables[tableName].Cols = append(tables[tableName].Cols, col)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("querying tables: %w", err)
 	}
 
 	// Convert map to slice
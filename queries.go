@@ -1,6 +1,7 @@
 package pg_mini
 
 import (
+	"cmp"
 	"fmt"
 	"slices"
 	"strings"
@@ -8,29 +9,70 @@ import (
 
 type ExportTableQueries struct {
 	Table       string
+	File        string // X.csv, X.bin or X.parquet
 	CreateTmp   string // CREATE TEMP TABLE ...
 	CreateIndex string // CREATE INDEX ... (empty if no index needed)
-	CopyToCSV   string // COPY tmp_mini_X TO STDOUT ...
+	CopyToCSV   string // COPY tmp_mini_X TO STDOUT ..., or SELECT * FROM tmp_mini_X for FormatParquet
 }
 
 type ImportTableQueries struct {
-	Table    string
-	Columns  []string
-	Truncate string // TRUNCATE TABLE X CASCADE
-	Copy     string // COPY X FROM STDIN ...
-	Insert   string // INSERT INTO X (...) VALUES (...)
+	Table    string   // table name in the backup
+	Target   string   // table name in the target database
+	Columns  []string // CSV columns bound to the row-by-row placeholders
+	File     string   // X.csv, X.bin or X.parquet, depending on the export format
+	Format   Format   // format of File
+	Truncate string   // TRUNCATE TABLE X CASCADE
+	Copy     string   // COPY X FROM STDIN ... (empty when the CSV must be staged in a temp table first)
+	Insert   string   // INSERT INTO X (...) VALUES (...)
 
 	// Upsert mode: COPY into temp table, then INSERT ... ON CONFLICT
 	CreateTemp    string // CREATE TEMP TABLE tmp_import_X (LIKE X INCLUDING ALL)
 	CopyTemp      string // COPY tmp_import_X FROM STDIN WITH CSV HEADER ...
+	InsertTemp    string // INSERT INTO X SELECT * FROM tmp
 	Upsert        string // INSERT INTO X SELECT * FROM tmp ON CONFLICT (...) DO UPDATE SET ...
 	SoftInsert    string // INSERT INTO X SELECT * FROM tmp ON CONFLICT (...) DO NOTHING
 	RowUpsert     string // INSERT INTO X (...) VALUES (...) ON CONFLICT (...) DO UPDATE SET ...
 	RowSoftInsert string // INSERT INTO X (...) VALUES (...) ON CONFLICT (...) DO NOTHING
 	DropTemp      string // DROP TABLE IF EXISTS tmp_import_X
+
+	// Clone mode: new surrogate keys, foreign keys rewritten through old→new maps
+	CloneKeys   string // CREATE TEMP TABLE tmp_clone_X AS SELECT id AS pg_mini_old, nextval(...) AS pg_mini_new FROM tmp_import_X
+	CloneInsert string // INSERT INTO X (...) SELECT ... FROM tmp_import_X AS src LEFT JOIN tmp_clone_Y ...
+	CloneDrop   string // DROP TABLE IF EXISTS tmp_clone_X
+
+	// fileCols are the columns of File in COPY order
+	fileCols []columnSchema
+	// insertCols are the target columns inserted, after mapping and schema
+	// drift adaptation
+	insertCols []string
+	// textCols are staged in the temp table as text because their backup
+	// type is unknown or the target lacks them
+	textCols []string
+
+	// Sync mode: upsert counting inserts and updates, then delete the rows of the exported subset missing from the backup
+	SyncUpsert string // WITH upserted AS (INSERT ... ON CONFLICT ... RETURNING (xmax = 0)) SELECT count(*) FILTER ...
+	SyncDelete string // DELETE FROM X WHERE (pk) IN (SELECT pk FROM tmp_mini_X) AND NOT EXISTS (SELECT 1 FROM tmp_import_X i WHERE i.pk IS NOT DISTINCT FROM X.pk)
 }
 
-func generateExportQueries(g *Graph, filter, raw string) []ExportTableQueries {
+type importQueryOpts struct {
+	// Target is the schema of the database being imported into. When set,
+	// tables missing from the target are skipped, only columns present in
+	// both schemas are inserted, and conflict targets come from the target.
+	Target *Schema
+	// Mapping renames tables and columns and fills target columns from
+	// expressions. Mapped tables are always staged in a temp table.
+	Mapping Mapping
+	// Clone generates clone queries for the subtree tables and leaves every
+	// other table out.
+	Clone *cloneOpts
+	// Upsert overrides the conflict target and update columns per table.
+	Upsert UpsertPolicies
+	// Formats holds the format each table was exported in, from the
+	// manifest. Tables not listed are CSV.
+	Formats map[string]Format
+}
+
+func generateExportQueries(g *Graph, filter, raw string, format Format) []ExportTableQueries {
 	var result []ExportTableQueries
 
 	for _, tbl := range g.ExportOrder {
@@ -51,8 +93,9 @@ func generateExportQueries(g *Graph, filter, raw string) []ExportTableQueries {
 
 		tq := ExportTableQueries{
 			Table:     tbl,
+			File:      format.fileName(tbl),
 			CreateTmp: fmt.Sprintf(`CREATE TEMP TABLE %s AS (%s);`, tmpTblName(tbl), selectQuery),
-			CopyToCSV: fmt.Sprintf("COPY %s TO STDOUT WITH CSV HEADER DELIMITER ',';", tmpTblName(tbl)),
+			CopyToCSV: format.exportQuery(tmpTblName(tbl)),
 		}
 
 		// Build index query
@@ -78,86 +121,209 @@ func generateExportQueries(g *Graph, filter, raw string) []ExportTableQueries {
 	return result
 }
 
-func generateImportQueries(g *Graph, schema *Schema) []ImportTableQueries {
+func generateImportQueries(g *Graph, schema *Schema, opts importQueryOpts) []ImportTableQueries {
 	var result []ImportTableQueries
+	dependents := dependentTables(g)
 
 	for _, tbl := range g.ImportOrder {
 		tblSchema := schema.Tables[tbl]
+		target := opts.Mapping.target(tbl)
+		_, mapped := opts.Mapping[tbl]
 
 		// Determine non-generated columns for COPY
-		var includeCols []string
+		var csvCols []string
+		var csvColDefs []string
+		var fileCols []columnSchema
+		var untypedCols []string
 		for _, col := range tblSchema.Cols {
 			if !col.Generated {
-				includeCols = append(includeCols, col.Name)
+				csvCols = append(csvCols, col.Name)
+				fileCols = append(fileCols, col)
+
+				colType := col.Type
+				if colType == "" {
+					colType = "text"
+					untypedCols = append(untypedCols, col.Name)
+				}
+				csvColDefs = append(csvColDefs, fmt.Sprintf("%s %s", col.Name, colType))
+			}
+		}
+
+		// Target columns and the expressions that fill them from the CSV
+		includeCols, exprs := opts.Mapping.project(tbl, csvCols)
+
+		// Conflict target columns, in target naming
+		conflictSchema := tableSchema{
+			PrimaryKeyCols: opts.Mapping.columns(tbl, tblSchema.PrimaryKeyCols),
+		}
+		for _, uc := range tblSchema.UniqueConstraints {
+			if mc := opts.Mapping.columns(tbl, uc); mc != nil {
+				conflictSchema.UniqueConstraints = append(conflictSchema.UniqueConstraints, mc)
 			}
 		}
+
+		// Restrict inserted columns to those the target has. Columns only in the
+		// CSV are carried in the temp table and never inserted.
+		var droppedCols []string
+		if opts.Target != nil {
+			targetSchema, ok := opts.Target.Tables[target]
+			if !ok {
+				continue
+			}
+			conflictSchema = targetSchema
+
+			targetCols := map[string]bool{}
+			for _, col := range targetSchema.Cols {
+				if !col.Generated {
+					targetCols[col.Name] = true
+				}
+			}
+			var keptCols, keptExprs []string
+			for idx, col := range includeCols {
+				if targetCols[col] {
+					keptCols = append(keptCols, col)
+					keptExprs = append(keptExprs, exprs[idx])
+				} else {
+					droppedCols = append(droppedCols, col)
+				}
+			}
+			includeCols, exprs = keptCols, keptExprs
+		}
+		staged := mapped || len(droppedCols) > 0
+
+		csvColList := strings.Join(csvCols, ", ")
 		colList := strings.Join(includeCols, ", ")
+		selectList := strings.Join(exprs, ", ")
+
+		tmpName := "tmp_import_" + tbl
+		format := cmp.Or(opts.Formats[tbl], FormatCSV)
+
+		// Mapped tables stage the CSV in a temp table shaped like the backup.
+		// Otherwise the temp table is shaped like the target, plus any CSV
+		// columns the target lacks.
+		var createTemp string
+		var textCols []string
+		if mapped {
+			createTemp = fmt.Sprintf("CREATE TEMP TABLE %s (%s);", tmpName, strings.Join(csvColDefs, ", "))
+			textCols = untypedCols
+		} else {
+			textCols = droppedCols
+			tmpCols := ""
+			for _, col := range droppedCols {
+				tmpCols += fmt.Sprintf(", %s text", col)
+			}
+			createTemp = fmt.Sprintf("CREATE TEMP TABLE %s (LIKE %s INCLUDING ALL%s);", tmpName, target, tmpCols)
+		}
+
+		// Row-by-row source: plain VALUES, or a projection over the bound CSV
+		// columns for mapped tables.
+		rowCols := includeCols
 		placeholders := make([]string, len(includeCols))
 		for idx := range includeCols {
 			placeholders[idx] = fmt.Sprintf("$%d", idx+1)
 		}
-		placeholderList := strings.Join(placeholders, ", ")
-
-		tmpName := "tmp_import_" + tbl
+		rowSource := fmt.Sprintf("VALUES (%s)", strings.Join(placeholders, ", "))
+		if mapped {
+			rowCols = csvCols
+			params := make([]string, len(csvCols))
+			for idx, def := range csvColDefs {
+				name, colType, _ := strings.Cut(def, " ")
+				params[idx] = fmt.Sprintf("$%d::%s AS %s", idx+1, colType, name)
+			}
+			rowSource = fmt.Sprintf("SELECT %s FROM (SELECT %s) AS src", selectList, strings.Join(params, ", "))
+		}
 
 		tq := ImportTableQueries{
 			Table:      tbl,
-			Columns:    includeCols,
-			Truncate:   fmt.Sprintf("TRUNCATE TABLE %s CASCADE;", tbl),
-			Copy:       fmt.Sprintf("COPY %s (%s) FROM STDIN WITH CSV HEADER DELIMITER ',';", tbl, colList),
-			Insert:     fmt.Sprintf("INSERT INTO %s (%s) OVERRIDING SYSTEM VALUE VALUES (%s);", tbl, colList, placeholderList),
-			CreateTemp: fmt.Sprintf("CREATE TEMP TABLE %s (LIKE %s INCLUDING ALL);", tmpName, tbl),
-			CopyTemp:   fmt.Sprintf("COPY %s (%s) FROM STDIN WITH CSV HEADER DELIMITER ',';", tmpName, colList),
+			Target:     target,
+			Columns:    rowCols,
+			File:       format.fileName(tbl),
+			Format:     format,
+			fileCols:   fileCols,
+			insertCols: includeCols,
+			textCols:   textCols,
+			Truncate:   fmt.Sprintf("TRUNCATE TABLE %s CASCADE;", target),
+			Insert:     fmt.Sprintf("INSERT INTO %s (%s) OVERRIDING SYSTEM VALUE %s;", target, colList, rowSource),
+			CreateTemp: createTemp,
+			CopyTemp:   fmt.Sprintf("COPY %s (%s) FROM STDIN WITH %s;", tmpName, csvColList, format.importOptions()),
+			InsertTemp: fmt.Sprintf("INSERT INTO %s (%s) OVERRIDING SYSTEM VALUE SELECT %s FROM %s;", target, colList, selectList, tmpName),
 			DropTemp:   fmt.Sprintf("DROP TABLE IF EXISTS %s;", tmpName),
 		}
+		if !staged {
+			tq.Copy = fmt.Sprintf("COPY %s (%s) FROM STDIN WITH %s;", target, colList, format.importOptions())
+		}
+
+		if opts.Clone != nil {
+			if !slices.Contains(opts.Clone.Subtree, tbl) {
+				continue
+			}
+			opts.Clone.fillQueries(g, schema, &tq, csvCols, includeCols, exprs)
+		}
 
 		// Determine conflict target columns: prefer primary key, fall back to first unique constraint
-		var conflictCols []string
-		if len(tblSchema.PrimaryKeyCols) > 0 {
-			conflictCols = tblSchema.PrimaryKeyCols
-		} else if len(tblSchema.UniqueConstraints) > 0 {
-			conflictCols = tblSchema.UniqueConstraints[0]
+		var defaultConflictCols []string
+		if len(conflictSchema.PrimaryKeyCols) > 0 {
+			defaultConflictCols = conflictSchema.PrimaryKeyCols
+		} else if len(conflictSchema.UniqueConstraints) > 0 {
+			defaultConflictCols = conflictSchema.UniqueConstraints[0]
 		}
+		policy := opts.Upsert[tbl]
+		conflictTarget, conflictCols := policy.conflictTarget(defaultConflictCols)
 
 		// Generate upsert query if we have a conflict target
-		if len(conflictCols) > 0 {
-			conflictColList := strings.Join(conflictCols, ", ")
-
-			// Build SET clause for non-conflict, non-generated columns
-			var setClauses []string
-			conflictSet := make(map[string]bool)
-			for _, c := range conflictCols {
-				conflictSet[c] = true
-			}
-			for _, col := range tblSchema.Cols {
-				if !col.Generated && !conflictSet[col.Name] {
-					setClauses = append(setClauses, fmt.Sprintf("%s = EXCLUDED.%s", col.Name, col.Name))
-				}
-			}
-
-			doClause := "DO NOTHING"
-			if len(setClauses) > 0 {
-				doClause = "DO UPDATE SET " + strings.Join(setClauses, ", ")
-			}
+		if conflictTarget != "" {
+			doClause := policy.doUpdate(target, includeCols, conflictCols)
 
 			tq.Upsert = fmt.Sprintf(
-				"INSERT INTO %s (%s) SELECT %s FROM %s ON CONFLICT (%s) %s;",
-				tbl, colList, colList, tmpName, conflictColList, doClause,
+				"INSERT INTO %s (%s) SELECT %s FROM %s ON CONFLICT %s %s;",
+				target, colList, selectList, tmpName, conflictTarget, doClause,
 			)
 
 			tq.SoftInsert = fmt.Sprintf(
-				"INSERT INTO %s (%s) SELECT %s FROM %s ON CONFLICT (%s) DO NOTHING;",
-				tbl, colList, colList, tmpName, conflictColList,
+				"INSERT INTO %s (%s) SELECT %s FROM %s ON CONFLICT %s DO NOTHING;",
+				target, colList, selectList, tmpName, conflictTarget,
 			)
 
 			tq.RowUpsert = fmt.Sprintf(
-				"INSERT INTO %s (%s) OVERRIDING SYSTEM VALUE VALUES (%s) ON CONFLICT (%s) %s;",
-				tbl, colList, placeholderList, conflictColList, doClause,
+				"INSERT INTO %s (%s) OVERRIDING SYSTEM VALUE %s ON CONFLICT %s %s;",
+				target, colList, rowSource, conflictTarget, doClause,
 			)
 
 			tq.RowSoftInsert = fmt.Sprintf(
-				"INSERT INTO %s (%s) OVERRIDING SYSTEM VALUE VALUES (%s) ON CONFLICT (%s) DO NOTHING;",
-				tbl, colList, placeholderList, conflictColList,
+				"INSERT INTO %s (%s) OVERRIDING SYSTEM VALUE %s ON CONFLICT %s DO NOTHING;",
+				target, colList, rowSource, conflictTarget,
+			)
+
+			// xmax is 0 for freshly inserted rows and set for updated ones
+			if !mapped {
+				tq.SyncUpsert = fmt.Sprintf(
+					"WITH upserted AS (INSERT INTO %s (%s) SELECT %s FROM %s ON CONFLICT %s %s RETURNING (xmax = 0) AS inserted) "+
+						"SELECT count(*) FILTER (WHERE inserted), count(*) FILTER (WHERE NOT inserted) FROM upserted;",
+					target, colList, selectList, tmpName, conflictTarget, doClause,
+				)
+			}
+		}
+
+		// Rows are matched by primary key, else by the conflict target. The
+		// subset in tmp_mini_X is recomputed on the target with the export
+		// queries, so it is only available for unmapped tables. NOT EXISTS
+		// rather than NOT IN: a NULL key in the backup, possible for a unique
+		// conflict target, would make NOT IN match nothing and delete no rows.
+		// Only the root and its dependents are deleted from: a referenced
+		// table's rows may be shared with other roots.
+		keyCols := conflictSchema.PrimaryKeyCols
+		if len(keyCols) == 0 {
+			keyCols = conflictCols
+		}
+		if len(keyCols) > 0 && !mapped && slices.Contains(dependents, tbl) {
+			keyColList := strings.Join(keyCols, ", ")
+			keyMatch := make([]string, len(keyCols))
+			for idx, col := range keyCols {
+				keyMatch[idx] = fmt.Sprintf("i.%s IS NOT DISTINCT FROM %s.%s", col, target, col)
+			}
+			tq.SyncDelete = fmt.Sprintf(
+				"DELETE FROM %s WHERE (%s) IN (SELECT %s FROM %s) AND NOT EXISTS (SELECT 1 FROM %s i WHERE %s);",
+				target, keyColList, keyColList, tmpTblName(tbl), tmpName, strings.Join(keyMatch, " AND "),
 			)
 		}
 
@@ -49,7 +49,7 @@ func Test_generateExportQueries(t *testing.T) {
 				t.Fatalf("buildGraph: %v", err)
 			}
 
-			queries := generateExportQueries(graph, tt.filter, tt.raw)
+			queries := generateExportQueries(graph, tt.filter, tt.raw, FormatCSV)
 
 			goldenFile := filepath.Join(tt.dir, "export_queries.json")
 
@@ -123,7 +123,7 @@ func Test_generateImportQueries(t *testing.T) {
 				t.Fatalf("buildGraph: %v", err)
 			}
 
-			queries := generateImportQueries(graph, schema)
+			queries := generateImportQueries(graph, schema, importQueryOpts{})
 
 			goldenFile := filepath.Join(tt.dir, "import_queries.json")
 
@@ -159,3 +159,36 @@ func Test_generateImportQueries(t *testing.T) {
 		})
 	}
 }
+
+func Test_generateImportQueries_SyncDelete(t *testing.T) {
+	// Companies share tags, so a sync of one company must not delete a tag
+	// another company still uses
+	schema := &Schema{
+		Tables: map[string]tableSchema{
+			"company":     {Name: "company", Cols: []columnSchema{{Name: "id"}}, PrimaryKeyCols: []string{"id"}},
+			"tag":         {Name: "tag", Cols: []columnSchema{{Name: "id"}}, PrimaryKeyCols: []string{"id"}},
+			"company_tag": {Name: "company_tag", Cols: []columnSchema{{Name: "company_id"}, {Name: "tag_id"}}, PrimaryKeyCols: []string{"company_id", "tag_id"}},
+		},
+		Relations: []foreignKeyRelation{
+			{FromTable: "company_tag", FromColumn: "company_id", ToTable: "company", ToColumn: "id"},
+			{FromTable: "company_tag", FromColumn: "tag_id", ToTable: "tag", ToColumn: "id"},
+		},
+	}
+	graph, err := buildGraph(schema, "company")
+	if err != nil {
+		t.Fatalf("buildGraph: %v", err)
+	}
+
+	got := map[string]string{}
+	for _, tq := range generateImportQueries(graph, schema, importQueryOpts{}) {
+		got[tq.Table] = tq.SyncDelete
+	}
+	want := map[string]string{
+		"company":     "DELETE FROM company WHERE (id) IN (SELECT id FROM tmp_mini_company) AND NOT EXISTS (SELECT 1 FROM tmp_import_company i WHERE i.id IS NOT DISTINCT FROM company.id);",
+		"tag":         "",
+		"company_tag": "DELETE FROM company_tag WHERE (company_id, tag_id) IN (SELECT company_id, tag_id FROM tmp_mini_company_tag) AND NOT EXISTS (SELECT 1 FROM tmp_import_company_tag i WHERE i.company_id IS NOT DISTINCT FROM company_tag.company_id AND i.tag_id IS NOT DISTINCT FROM company_tag.tag_id);",
+	}
+	if diff := deep.Equal(got, want); diff != nil {
+		t.Error(diff)
+	}
+}
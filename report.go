@@ -0,0 +1,156 @@
+package pg_mini
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// ExportReport summarizes an export run. Export.RunWithReport returns it and
+// it is saved to the Store as report.json.
+type ExportReport struct {
+	RootTable string
+	Filter    string
+	RawQuery  string
+	Started   time.Time
+	Duration  time.Duration
+	Error     string // set when the export failed part way
+
+	// Totals over all tables
+	Rows  int64
+	Bytes int64
+
+	Tables []ExportTableReport // in export order
+}
+
+// ExportTableReport holds the stats of one exported table.
+type ExportTableReport struct {
+	Table        string
+	File         string
+	Rows         int64
+	Bytes        int64
+	CopyDuration time.Duration // selecting the subset into the temp table
+	CSVDuration  time.Duration // writing the temp table to the Store
+}
+
+// Report names in the Store. An import reads the backup from the Store its
+// export wrote, so its report gets a name of its own instead of replacing the
+// export's report.json.
+const (
+	exportReportName = "report.json"
+	importReportName = "import_report.json"
+)
+
+// ImportReport summarizes an import run. Import.RunWithReport returns it and
+// it is saved to the Store as import_report.json.
+type ImportReport struct {
+	RootTable  string
+	Mode       string // copy, truncate, upsert, soft-insert, clone or sync
+	SkipErrors bool
+	Started    time.Time
+	Duration   time.Duration
+	Error      string // set when the import failed part way
+
+	// Totals over all tables
+	Rows     int64
+	Bytes    int64
+	Written  int64
+	Inserted int64
+	Updated  int64
+	Deleted  int64
+	Skipped  int64
+	Failed   int64
+
+	Tables []ImportTableReport // in import order
+}
+
+// ImportTableReport holds the stats of one imported table. Inserted and
+// Updated are only told apart in sync mode; bulk upserts count both as
+// Written.
+type ImportTableReport struct {
+	Table    string // table name in the backup
+	Target   string // table name in the target database
+	Mode     string // copy, staged, upsert, soft-insert, clone, sync, or insert/upsert/soft-insert row by row with SkipErrors
+	Fallback bool   // SkipErrors fell back to plain inserts, the table has no primary key or unique constraint
+	File     string
+	Rows     int64 // rows read from the backup
+	Bytes    int64 // bytes read from the backup
+	Written  int64 // rows inserted or updated in the target
+	Inserted int64
+	Updated  int64
+	Deleted  int64
+	Skipped  int64  // conflicting rows left alone
+	Failed   int64  // rows rejected with SkipErrors
+	Rejected string // file holding the rejected rows, see Import.RetryRejected
+	Duration time.Duration
+}
+
+func (r *ExportReport) finish(t0 time.Time, err error) {
+	r.Duration = time.Since(t0)
+	if err != nil {
+		r.Error = err.Error()
+	}
+	r.Rows, r.Bytes = 0, 0
+	for _, t := range r.Tables {
+		r.Rows += t.Rows
+		r.Bytes += t.Bytes
+	}
+}
+
+func (r *ImportReport) finish(t0 time.Time, err error) {
+	r.Duration = time.Since(t0)
+	if err != nil {
+		r.Error = err.Error()
+	}
+	r.Rows, r.Bytes, r.Written, r.Inserted, r.Updated, r.Deleted, r.Skipped, r.Failed = 0, 0, 0, 0, 0, 0, 0, 0
+	for _, t := range r.Tables {
+		r.Rows += t.Rows
+		r.Bytes += t.Bytes
+		r.Written += t.Written
+		r.Inserted += t.Inserted
+		r.Updated += t.Updated
+		r.Deleted += t.Deleted
+		r.Skipped += t.Skipped
+		r.Failed += t.Failed
+	}
+}
+
+// WriteTable prints the report as an aligned text table.
+func (r *ExportReport) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TABLE\tROWS\tSIZE\tCOPY\tCSV")
+	for _, t := range r.Tables {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\n",
+			t.Table, t.Rows, prettyFileSize(t.Bytes), prettyDuration(t.CopyDuration), prettyDuration(t.CSVDuration))
+	}
+	fmt.Fprintf(tw, "TOTAL\t%d\t%s\t\t%s\n", r.Rows, prettyFileSize(r.Bytes), prettyDuration(r.Duration))
+	if r.Error != "" {
+		fmt.Fprintf(tw, "ERROR\t%s\n", r.Error)
+	}
+	return tw.Flush()
+}
+
+// WriteTable prints the report as an aligned text table.
+func (r *ImportReport) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TABLE\tMODE\tROWS\tSIZE\tWRITTEN\tINSERTED\tUPDATED\tDELETED\tSKIPPED\tFAILED\tDURATION")
+	for _, t := range r.Tables {
+		mode := t.Mode
+		if t.Fallback {
+			mode += " (fallback)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%s\n",
+			t.Table, mode, t.Rows, prettyFileSize(t.Bytes),
+			t.Written, t.Inserted, t.Updated, t.Deleted, t.Skipped, t.Failed,
+			prettyDuration(t.Duration))
+	}
+	fmt.Fprintf(tw, "TOTAL\t%s\t%d\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%s\n",
+		r.Mode, r.Rows, prettyFileSize(r.Bytes),
+		r.Written, r.Inserted, r.Updated, r.Deleted, r.Skipped, r.Failed,
+		prettyDuration(r.Duration))
+	if r.Error != "" {
+		fmt.Fprintf(tw, "ERROR\t%s\n", r.Error)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,36 @@
+package pg_mini
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImportReport_finish(t *testing.T) {
+	report := &ImportReport{
+		Mode: "soft-insert",
+		Tables: []ImportTableReport{
+			{Table: "company", Mode: "soft-insert", Rows: 3, Bytes: 120, Written: 2, Inserted: 2, Skipped: 1},
+			{Table: "employee", Mode: "soft-insert", Rows: 10, Bytes: 800, Written: 10, Inserted: 10},
+		},
+	}
+	report.finish(time.Now(), errors.New("boom"))
+
+	if report.Rows != 13 || report.Bytes != 920 || report.Written != 12 || report.Inserted != 12 || report.Skipped != 1 {
+		t.Errorf("unexpected totals: %+v", report)
+	}
+	if report.Error != "boom" {
+		t.Errorf("Error = %q, want boom", report.Error)
+	}
+
+	var sb strings.Builder
+	if err := report.WriteTable(&sb); err != nil {
+		t.Fatalf("WriteTable: %v", err)
+	}
+	for _, want := range []string{"company", "employee", "TOTAL", "ERROR"} {
+		if !strings.Contains(sb.String(), want) {
+			t.Errorf("table output missing %q:\n%s", want, sb.String())
+		}
+	}
+}
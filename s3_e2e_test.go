@@ -1,7 +1,9 @@
 package pg_mini
 
 import (
+	"bytes"
 	"context"
+	"io"
 	"testing"
 
 	"github.com/fritzkeyzer/pg_mini/s3_store"
@@ -10,6 +12,8 @@ import (
 	tcminio "github.com/testcontainers/testcontainers-go/modules/minio"
 )
 
+var _ ExtendedStore = (*s3_store.Store)(nil)
+
 // startMinio boots a MinIO container, creates the bucket, and returns a Config
 // pointing at it.
 func startMinio(t *testing.T, bucket string) s3_store.Config {
@@ -111,3 +115,112 @@ func TestE2E_S3RoundTrip(t *testing.T) {
 	restored := snapshotDB(t, connect(t, connStr))
 	compareSnapshots(t, original, restored)
 }
+
+func TestE2E_S3ObjectOptions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test in short mode")
+	}
+
+	ctx := context.Background()
+	connStr := startPostgres(t)
+	execSQLFile(t, connect(t, connStr), "testdata/e2e/company/setup.sql")
+
+	cfg := startMinio(t, "pg-mini-test")
+	cfg.StorageClass = "REDUCED_REDUNDANCY"
+	cfg.Metadata = map[string]string{"Owner": "data-team"}
+	cfg.Tags = map[string]string{"root_table": "company", "env": "test"}
+
+	store, err := s3_store.New(ctx, cfg)
+	if err != nil {
+		t.Fatalf("new s3 store: %v", err)
+	}
+	exp := &Export{
+		DB:           connect(t, connStr),
+		RootTable:    "company",
+		Store:        store,
+		NoAnimations: true,
+	}
+	if err := exp.Run(ctx); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKeyID, cfg.SecretAccessKey, ""),
+		Secure: cfg.UseSSL,
+	})
+	if err != nil {
+		t.Fatalf("create minio client: %v", err)
+	}
+
+	info, err := client.StatObject(ctx, cfg.Bucket, "company.csv", minio.StatObjectOptions{})
+	if err != nil {
+		t.Fatalf("stat company.csv: %v", err)
+	}
+	if info.StorageClass != cfg.StorageClass {
+		t.Errorf("storage class: want %s, got %s", cfg.StorageClass, info.StorageClass)
+	}
+	if got := info.UserMetadata["Owner"]; got != "data-team" {
+		t.Errorf("metadata Owner: want data-team, got %q (%v)", got, info.UserMetadata)
+	}
+
+	tags, err := client.GetObjectTagging(ctx, cfg.Bucket, "company.csv", minio.GetObjectTaggingOptions{})
+	if err != nil {
+		t.Fatalf("get tags: %v", err)
+	}
+	for key, want := range cfg.Tags {
+		if got := tags.ToMap()[key]; got != want {
+			t.Errorf("tag %s: want %s, got %q", key, want, got)
+		}
+	}
+}
+
+func TestE2E_S3MultipartAndRanges(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test in short mode")
+	}
+
+	ctx := context.Background()
+	cfg := startMinio(t, "pg-mini-test")
+	cfg.PartSize = 5 << 20
+	cfg.UploadConcurrency = 3
+	cfg.ReadChunkSize = 1 << 20
+	cfg.ReadConcurrency = 4
+
+	store, err := s3_store.New(ctx, cfg)
+	if err != nil {
+		t.Fatalf("new s3 store: %v", err)
+	}
+
+	// Four full parts and a partial one, written in odd-sized chunks.
+	want := make([]byte, 4*cfg.PartSize+12345)
+	for i := range want {
+		want[i] = byte(i % 251)
+	}
+	w, err := store.Create("big.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rest := want; len(rest) > 0; {
+		n := min(len(rest), 777777)
+		if _, err := w.Write(rest[:n]); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		rest = rest[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	f, err := store.Open("big.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	got, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("read back %d bytes, differing from the %d written", len(got), len(want))
+	}
+}
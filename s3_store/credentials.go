@@ -0,0 +1,98 @@
+package s3_store
+
+import (
+	"fmt"
+
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// defaultRoleSessionName names the assumed-role sessions pg_mini opens.
+const defaultRoleSessionName = "pg_mini"
+
+// newCredentials builds the credentials of cfg. Static keys win. Otherwise
+// the first source that yields keys is used:
+//   - AWS_ACCESS_KEY_ID / AWS_SECRET_ACCESS_KEY / AWS_SESSION_TOKEN
+//   - the shared credentials file (AWS_SHARED_CREDENTIALS_FILE or
+//     ~/.aws/credentials), profile AWS_PROFILE or "default", including
+//     credential_process
+//   - IAM: a web identity token (AWS_WEB_IDENTITY_TOKEN_FILE and AWS_ROLE_ARN,
+//     as set by EKS IRSA), ECS container credentials or the EC2 instance role
+//
+// Config.Profile pins the shared credentials file profile instead. With
+// Config.RoleARN the resolved keys are only used to assume that role.
+func newCredentials(cfg Config) *credentials.Credentials {
+	var base *credentials.Credentials
+	switch {
+	case cfg.AccessKeyID != "" || cfg.SecretAccessKey != "":
+		base = credentials.NewStaticV4(cfg.AccessKeyID, cfg.SecretAccessKey, cfg.SessionToken)
+	case cfg.Profile != "":
+		base = credentials.NewFileAWSCredentials("", cfg.Profile)
+	default:
+		base = credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.FileAWSCredentials{},
+			&credentials.IAM{},
+		})
+	}
+	if cfg.RoleARN == "" {
+		return base
+	}
+
+	endpoint := cfg.STSEndpoint
+	if endpoint == "" {
+		endpoint = credentials.DefaultSTSRoleEndpoint
+		if cfg.Region != "" {
+			endpoint = fmt.Sprintf("https://sts.%s.amazonaws.com", cfg.Region)
+		}
+	}
+	sessionName := cfg.RoleSessionName
+	if sessionName == "" {
+		sessionName = defaultRoleSessionName
+	}
+	return credentials.New(&assumeRole{
+		base:     base,
+		endpoint: endpoint,
+		opts: credentials.STSAssumeRoleOptions{
+			RoleARN:         cfg.RoleARN,
+			RoleSessionName: sessionName,
+			ExternalID:      cfg.ExternalID,
+			Location:        cfg.Region,
+		},
+	})
+}
+
+// assumeRole is a credentials.Provider assuming a role with keys from base.
+// The base keys are resolved again on every refresh, so short-lived ones
+// (web identity, session tokens) keep working for long runs.
+type assumeRole struct {
+	base     *credentials.Credentials
+	endpoint string
+	opts     credentials.STSAssumeRoleOptions
+	cur      *credentials.STSAssumeRole
+}
+
+func (a *assumeRole) Retrieve() (credentials.Value, error) {
+	return a.RetrieveWithCredContext(nil)
+}
+
+func (a *assumeRole) RetrieveWithCredContext(cc *credentials.CredContext) (credentials.Value, error) {
+	v, err := a.base.GetWithContext(cc)
+	if err != nil {
+		return credentials.Value{}, fmt.Errorf("base credentials to assume %s: %w", a.opts.RoleARN, err)
+	}
+
+	sts := &credentials.STSAssumeRole{STSEndpoint: a.endpoint, Options: a.opts}
+	sts.Options.AccessKey = v.AccessKeyID
+	sts.Options.SecretKey = v.SecretAccessKey
+	sts.Options.SessionToken = v.SessionToken
+	val, err := sts.RetrieveWithCredContext(cc)
+	if err != nil {
+		return credentials.Value{}, fmt.Errorf("assume role %s: %w", a.opts.RoleARN, err)
+	}
+	a.cur = sts
+	return val, nil
+}
+
+func (a *assumeRole) IsExpired() bool {
+	return a.cur == nil || a.cur.IsExpired()
+}
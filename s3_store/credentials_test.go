@@ -0,0 +1,164 @@
+package s3_store
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// clearAWSEnv isolates a test from the AWS settings of the machine running
+// it.
+func clearAWSEnv(t *testing.T) {
+	for _, key := range []string{
+		"AWS_ACCESS_KEY_ID", "AWS_ACCESS_KEY", "AWS_SECRET_ACCESS_KEY", "AWS_SECRET_KEY", "AWS_SESSION_TOKEN",
+		"AWS_PROFILE", "AWS_WEB_IDENTITY_TOKEN_FILE", "AWS_ROLE_ARN",
+		"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", "AWS_CONTAINER_CREDENTIALS_FULL_URI",
+	} {
+		t.Setenv(key, "")
+	}
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "missing"))
+}
+
+func writeSharedCredentials(t *testing.T, content string) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", path)
+}
+
+func Test_newCredentials(t *testing.T) {
+	const sharedFile = `[default]
+aws_access_key_id = DEFAULTKEY
+aws_secret_access_key = defaultsecret
+
+[dev]
+aws_access_key_id = DEVKEY
+aws_secret_access_key = devsecret
+aws_session_token = devtoken
+`
+	tests := []struct {
+		name      string
+		cfg       Config
+		env       map[string]string
+		file      string
+		wantKey   string
+		wantToken string
+	}{
+		{
+			name:      "static keys win",
+			cfg:       Config{AccessKeyID: "STATIC", SecretAccessKey: "secret", SessionToken: "tok"},
+			env:       map[string]string{"AWS_ACCESS_KEY_ID": "ENVKEY", "AWS_SECRET_ACCESS_KEY": "envsecret"},
+			wantKey:   "STATIC",
+			wantToken: "tok",
+		},
+		{
+			name:      "env with session token",
+			env:       map[string]string{"AWS_ACCESS_KEY_ID": "ENVKEY", "AWS_SECRET_ACCESS_KEY": "envsecret", "AWS_SESSION_TOKEN": "envtoken"},
+			file:      sharedFile,
+			wantKey:   "ENVKEY",
+			wantToken: "envtoken",
+		},
+		{
+			name:    "shared file default profile",
+			file:    sharedFile,
+			wantKey: "DEFAULTKEY",
+		},
+		{
+			name:      "AWS_PROFILE",
+			env:       map[string]string{"AWS_PROFILE": "dev"},
+			file:      sharedFile,
+			wantKey:   "DEVKEY",
+			wantToken: "devtoken",
+		},
+		{
+			name:      "pinned profile beats env",
+			cfg:       Config{Profile: "dev"},
+			env:       map[string]string{"AWS_ACCESS_KEY_ID": "ENVKEY", "AWS_SECRET_ACCESS_KEY": "envsecret"},
+			file:      sharedFile,
+			wantKey:   "DEVKEY",
+			wantToken: "devtoken",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAWSEnv(t)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			if tt.file != "" {
+				writeSharedCredentials(t, tt.file)
+			}
+
+			v, err := newCredentials(tt.cfg).Get()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v.AccessKeyID != tt.wantKey || v.SessionToken != tt.wantToken {
+				t.Fatalf("got key %q token %q, want %q %q", v.AccessKeyID, v.SessionToken, tt.wantKey, tt.wantToken)
+			}
+		})
+	}
+}
+
+func Test_newCredentials_AssumeRole(t *testing.T) {
+	clearAWSEnv(t)
+	t.Setenv("AWS_ACCESS_KEY_ID", "BASEKEY")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "basesecret")
+
+	var form map[string]string
+	var authorization string
+	sts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		form = map[string]string{}
+		for key := range r.PostForm {
+			form[key] = r.PostForm.Get(key)
+		}
+		authorization = r.Header.Get("Authorization")
+		fmt.Fprint(w, `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleResult>
+    <Credentials>
+      <AccessKeyId>ROLEKEY</AccessKeyId>
+      <SecretAccessKey>rolesecret</SecretAccessKey>
+      <SessionToken>roletoken</SessionToken>
+      <Expiration>2099-01-01T00:00:00Z</Expiration>
+    </Credentials>
+  </AssumeRoleResult>
+</AssumeRoleResponse>`)
+	}))
+	defer sts.Close()
+
+	creds := newCredentials(Config{
+		RoleARN:     "arn:aws:iam::123456789012:role/backups",
+		ExternalID:  "ext-42",
+		STSEndpoint: sts.URL,
+	})
+	v, err := creds.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.AccessKeyID != "ROLEKEY" || v.SessionToken != "roletoken" {
+		t.Fatalf("got key %q token %q, want the assumed role's", v.AccessKeyID, v.SessionToken)
+	}
+
+	for key, want := range map[string]string{
+		"Action":          "AssumeRole",
+		"RoleArn":         "arn:aws:iam::123456789012:role/backups",
+		"ExternalId":      "ext-42",
+		"RoleSessionName": defaultRoleSessionName,
+	} {
+		if form[key] != want {
+			t.Errorf("%s: got %q, want %q", key, form[key], want)
+		}
+	}
+	if !strings.Contains(authorization, "Credential=BASEKEY/") {
+		t.Errorf("STS request not signed with the base credentials: %s", authorization)
+	}
+}
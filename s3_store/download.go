@@ -0,0 +1,107 @@
+package s3_store
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// rangeReader reads an object as consecutive byte ranges of chunkSize,
+// fetching up to concurrency ranges ahead of the reader in parallel. Every
+// range is pinned to the ETag seen when the object was opened, so a
+// concurrent overwrite fails the read instead of mixing two versions.
+type rangeReader struct {
+	cancel  context.CancelFunc
+	pending chan chan rangeResult // in object order
+	cur     *bytes.Reader
+	err     error
+}
+
+type rangeResult struct {
+	data []byte
+	err  error
+}
+
+func newRangeReader(s *Store, key string, info minio.ObjectInfo) *rangeReader {
+	ctx, cancel := context.WithCancel(s.ctx)
+	r := &rangeReader{
+		cancel:  cancel,
+		pending: make(chan chan rangeResult, s.readConcurrency),
+		cur:     bytes.NewReader(nil),
+	}
+
+	go func() {
+		defer close(r.pending)
+		for off := int64(0); off < info.Size; off += s.readChunkSize {
+			end := min(off+s.readChunkSize, info.Size) - 1
+			res := make(chan rangeResult, 1)
+			select {
+			case r.pending <- res:
+			case <-ctx.Done():
+				return
+			}
+			go func() {
+				data, err := s.getRange(ctx, key, info.ETag, off, end)
+				res <- rangeResult{data: data, err: err}
+			}()
+		}
+	}()
+	return r
+}
+
+func (r *rangeReader) Read(p []byte) (int, error) {
+	for r.cur.Len() == 0 && r.err == nil {
+		res, ok := <-r.pending
+		if !ok {
+			r.err = io.EOF
+			break
+		}
+		next := <-res
+		if next.err != nil {
+			r.err = next.err
+			break
+		}
+		r.cur = bytes.NewReader(next.data)
+	}
+	if r.cur.Len() > 0 {
+		return r.cur.Read(p)
+	}
+	return 0, r.err
+}
+
+// Close stops fetching ranges that have not been read.
+func (r *rangeReader) Close() error {
+	r.cancel()
+	return nil
+}
+
+// getRange fetches bytes start through end (inclusive) of the object.
+func (s *Store) getRange(ctx context.Context, key, etag string, start, end int64) ([]byte, error) {
+	opts := minio.GetObjectOptions{ServerSideEncryption: s.ssec}
+	if err := opts.SetRange(start, end); err != nil {
+		return nil, err
+	}
+	if etag != "" {
+		if err := opts.SetMatchETag(etag); err != nil {
+			return nil, err
+		}
+	}
+
+	var data []byte
+	err := s.retry.do(ctx, func() error {
+		body, _, _, err := s.core.GetObject(ctx, s.bucket, key, opts)
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+		data, err = io.ReadAll(body)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read bytes %d-%d: %w", start, end, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,85 @@
+package s3_store
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// RetryPolicy controls how failed S3 requests are retried. Every request,
+// including each part of an upload and each range of a read, is retried on
+// its own with exponential backoff and full jitter.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of tries per request, default 5. 1
+	// disables retries.
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry, default 200ms. It
+	// doubles with every attempt up to MaxDelay, default 10s.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 5
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = 200 * time.Millisecond
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = 10 * time.Second
+	}
+	return p
+}
+
+// backoff returns the wait before retry number attempt (0 based): a random
+// duration up to min(MaxDelay, BaseDelay * 2^attempt).
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	ceiling := p.MaxDelay
+	if attempt < 32 {
+		if d := p.BaseDelay << attempt; d > 0 && d < ceiling {
+			ceiling = d
+		}
+	}
+	return rand.N(ceiling) + 1
+}
+
+// do runs fn until it succeeds, fails with an error that retrying cannot
+// fix, ctx is done or the attempts are used up.
+func (p RetryPolicy) do(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := range p.MaxAttempts {
+		if err = fn(); err == nil || !retryable(err) || attempt == p.MaxAttempts-1 {
+			return err
+		}
+		select {
+		case <-time.After(p.backoff(attempt)):
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		}
+	}
+	return err
+}
+
+// retryable reports whether err may go away on retry: network errors,
+// throttling, timeouts and server errors. Other 4xx responses such as
+// NoSuchKey or AccessDenied are final.
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	status := minio.ToErrorResponse(err).StatusCode
+	switch {
+	case status == 0:
+		return true
+	case status == http.StatusRequestTimeout, status == http.StatusTooManyRequests:
+		return true
+	case status >= 500:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,179 @@
+package s3_store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func Test_retryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "network", err: errors.New("connection reset by peer"), want: true},
+		{name: "throttled", err: minio.ErrorResponse{StatusCode: http.StatusTooManyRequests, Code: "SlowDown"}, want: true},
+		{name: "server error", err: minio.ErrorResponse{StatusCode: http.StatusServiceUnavailable}, want: true},
+		{name: "timeout", err: minio.ErrorResponse{StatusCode: http.StatusRequestTimeout}, want: true},
+		{name: "not found", err: minio.ErrorResponse{StatusCode: http.StatusNotFound, Code: "NoSuchKey"}},
+		{name: "denied", err: minio.ErrorResponse{StatusCode: http.StatusForbidden, Code: "AccessDenied"}},
+		{name: "canceled", err: context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryable(tt.err); got != tt.want {
+				t.Errorf("retryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}.withDefaults()
+	for attempt, ceiling := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		for range 100 {
+			if d := p.backoff(attempt); d <= 0 || d > ceiling*time.Millisecond {
+				t.Fatalf("attempt %d: backoff %v outside (0, %vms]", attempt, d, ceiling)
+			}
+		}
+	}
+	if d := p.backoff(100); d <= 0 || d > time.Second {
+		t.Fatalf("backoff overflowed: %v", d)
+	}
+}
+
+func TestRetryPolicy_do(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	unavailable := minio.ErrorResponse{StatusCode: http.StatusServiceUnavailable}
+
+	calls := 0
+	err := p.do(context.Background(), func() error {
+		calls++
+		if calls < 3 {
+			return unavailable
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("recovering request: err %v after %d calls, want success after 3", err, calls)
+	}
+
+	calls = 0
+	err = p.do(context.Background(), func() error { calls++; return unavailable })
+	if err == nil || calls != 3 {
+		t.Errorf("failing request: err %v after %d calls, want an error after 3", err, calls)
+	}
+
+	calls = 0
+	err = p.do(context.Background(), func() error {
+		calls++
+		return minio.ErrorResponse{StatusCode: http.StatusNotFound, Code: "NoSuchKey"}
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("final error: err %v after %d calls, want an error after 1", err, calls)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	slow := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour, MaxDelay: time.Hour}
+	if err := slow.do(ctx, func() error { return unavailable }); !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled: got %v, want context.Canceled", err)
+	}
+}
+
+// TestStore_OpenRanges serves one object from a fake S3 endpoint that fails
+// every first request for a range, so reads only succeed if each range is
+// fetched and retried on its own.
+func TestStore_OpenRanges(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 1000) // 16000 bytes
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	var requests atomic.Int64
+	var failed sync.Map // Range header -> struct{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path != "/bucket/data.csv" {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `<Error><Code>NoSuchKey</Code><Message>not found</Message></Error>`)
+			return
+		}
+		if rng := r.Header.Get("Range"); rng != "" {
+			if _, seen := failed.LoadOrStore(rng, struct{}{}); !seen {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		}
+		w.Header().Set("ETag", `"abc"`)
+		http.ServeContent(w, r, "data.csv", modTime, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	for _, tt := range []struct {
+		name        string
+		chunk       int64
+		concurrency int
+	}{
+		{name: "parallel ranges", chunk: 1000, concurrency: 4},
+		{name: "uneven last range", chunk: 3000, concurrency: 2},
+		{name: "single range", chunk: 1 << 20, concurrency: 4},
+		{name: "single stream", chunk: 1000, concurrency: 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := New(context.Background(), Config{
+				Endpoint:        strings.TrimPrefix(srv.URL, "http://"),
+				Bucket:          "bucket",
+				AccessKeyID:     "key",
+				SecretAccessKey: "secret",
+				Region:          "us-east-1",
+				ReadChunkSize:   tt.chunk,
+				ReadConcurrency: tt.concurrency,
+				Retry:           RetryPolicy{BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			f, err := s.Open("data.csv")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(f)
+			f.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Fatalf("read %d bytes, want the %d byte object", len(got), len(content))
+			}
+		})
+	}
+
+	s, err := New(context.Background(), Config{
+		Endpoint:        strings.TrimPrefix(srv.URL, "http://"),
+		Bucket:          "bucket",
+		AccessKeyID:     "key",
+		SecretAccessKey: "secret",
+		Region:          "us-east-1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := requests.Load()
+	if _, err := s.Open("missing.csv"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("open missing: got %v, want fs.ErrNotExist", err)
+	}
+	if n := requests.Load() - before; n != 1 {
+		t.Errorf("open missing took %d requests, want 1 (not found is not retried)", n)
+	}
+}
@@ -4,23 +4,58 @@
 package s3_store
 
 import (
+	"bytes"
+	"cmp"
 	"context"
 	"fmt"
 	"io"
+	"io/fs"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/minio/minio-go/v7"
-	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
 )
 
 // Store is a pg_mini Store backed by an S3 bucket. Object keys are formed by
-// joining Prefix with the requested name.
+// joining Prefix with the requested name. Besides Create and Open it
+// implements List, Stat and Delete (pg_mini.ExtendedStore).
 type Store struct {
 	client *minio.Client
+	core   minio.Core // multipart and ranged requests
 	bucket string
 	prefix string
 	ctx    context.Context
+
+	retry             RetryPolicy
+	partSize          int64
+	uploadConcurrency int
+	readChunkSize     int64
+	readConcurrency   int
+
+	// put is the template for every upload: encryption, storage class,
+	// metadata and tags.
+	put minio.PutObjectOptions
+	// ssec is the customer key, sent on every read too. Nil unless SSE is
+	// SSECustomer.
+	ssec encrypt.ServerSide
 }
 
+// SSE modes for Config.SSE.
+const (
+	// SSENone leaves encryption to the bucket default.
+	SSENone = ""
+	// SSES3 encrypts with S3-managed keys (SSE-S3).
+	SSES3 = "s3"
+	// SSEKMS encrypts with a KMS key (SSE-KMS), Config.KMSKeyID or the
+	// AWS-managed key when empty.
+	SSEKMS = "kms"
+	// SSECustomer encrypts with a key supplied on every request (SSE-C),
+	// Config.CustomerKey.
+	SSECustomer = "customer"
+)
+
 // Config configures a Store.
 type Config struct {
 	// Endpoint is the S3 host, e.g. "s3.amazonaws.com" or "localhost:9000".
@@ -29,40 +64,148 @@ type Config struct {
 	Bucket string
 	// Prefix is an optional key prefix (a virtual "directory") for all names.
 	Prefix string
-	// AccessKeyID and SecretAccessKey authenticate requests. If both are
-	// empty, the IAM credential chain is used instead.
+	// AccessKeyID and SecretAccessKey (plus SessionToken for temporary
+	// keys) authenticate requests. If both are empty, the AWS credential
+	// chain is used instead: env vars, the shared credentials file, then
+	// web identity, container or instance roles.
 	AccessKeyID     string
 	SecretAccessKey string
+	SessionToken    string
+	// Profile pins the shared credentials file profile, instead of
+	// AWS_PROFILE and the rest of the chain.
+	Profile string
+	// RoleARN, if set, is assumed with the credentials above through STS.
+	// ExternalID is passed along when the role's trust policy requires one.
+	RoleARN         string
+	ExternalID      string
+	RoleSessionName string // defaults to "pg_mini"
+	// STSEndpoint overrides the STS endpoint used to assume RoleARN.
+	// Defaults to the regional endpoint of Region, or the global one.
+	STSEndpoint string
 	// Region is optional; required by some endpoints (e.g. AWS).
 	Region string
 	// UseSSL toggles HTTPS. Set true for AWS/R2 etc.
 	UseSSL bool
+
+	// SSE selects server-side encryption: SSENone, SSES3, SSEKMS or
+	// SSECustomer.
+	SSE string
+	// KMSKeyID is the KMS key id or ARN for SSEKMS.
+	KMSKeyID string
+	// CustomerKey is the 32 byte key for SSECustomer. The same key is needed
+	// to read the objects back.
+	CustomerKey []byte
+
+	// StorageClass is the storage class of new objects, e.g. "STANDARD_IA".
+	// Empty uses the bucket default.
+	StorageClass string
+	// Metadata is stored as user metadata (x-amz-meta-*) on every object.
+	Metadata map[string]string
+	// Tags are set as object tags on every object, e.g. for lifecycle rules
+	// or cost allocation.
+	Tags map[string]string
+
+	// PartSize is the multipart upload part size in bytes, default 16 MiB,
+	// minimum 5 MiB. Objects smaller than one part are uploaded in a single
+	// request. S3 allows 10,000 parts per object, so the part size doubles
+	// after every 1000 parts, up to 5 GiB; objects of about 5 TiB fit even
+	// at the minimum. UploadConcurrency parts of an object are uploaded
+	// at once, default 4; each is buffered in memory, so very large objects
+	// need memory for concurrency times the grown part size.
+	PartSize          int64
+	UploadConcurrency int
+	// ReadChunkSize and ReadConcurrency make Open fetch objects larger than
+	// one chunk as byte ranges, ReadConcurrency at once ahead of the reader.
+	// Defaults are 8 MiB and 4; ReadConcurrency 1 reads a single stream.
+	ReadChunkSize   int64
+	ReadConcurrency int
+	// Retry controls how failed requests are retried.
+	Retry RetryPolicy
 }
 
+// Size limits and defaults of the transfer settings.
+const (
+	minPartSize              = 5 << 20
+	defaultPartSize          = 16 << 20
+	defaultUploadConcurrency = 4
+	defaultReadChunkSize     = 8 << 20
+	defaultReadConcurrency   = 4
+)
+
 // New builds a Store from cfg. ctx bounds every subsequent read and write.
 func New(ctx context.Context, cfg Config) (*Store, error) {
-	var creds *credentials.Credentials
-	if cfg.AccessKeyID != "" || cfg.SecretAccessKey != "" {
-		creds = credentials.NewStaticV4(cfg.AccessKeyID, cfg.SecretAccessKey, "")
-	} else {
-		creds = credentials.NewIAM("")
+	sse, err := serverSide(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.PartSize != 0 && cfg.PartSize < minPartSize {
+		return nil, fmt.Errorf("part size %d is below the S3 minimum of %d bytes", cfg.PartSize, minPartSize)
 	}
 
 	client, err := minio.New(cfg.Endpoint, &minio.Options{
-		Creds:  creds,
+		Creds:  newCredentials(cfg),
 		Secure: cfg.UseSSL,
 		Region: cfg.Region,
+		// Requests are retried by Config.Retry instead
+		MaxRetries: 1,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("create s3 client: %w", err)
 	}
 
-	return &Store{
-		client: client,
-		bucket: cfg.Bucket,
-		prefix: cfg.Prefix,
-		ctx:    ctx,
-	}, nil
+	s := &Store{
+		client:            client,
+		core:              minio.Core{Client: client},
+		bucket:            cfg.Bucket,
+		prefix:            cfg.Prefix,
+		ctx:               ctx,
+		retry:             cfg.Retry.withDefaults(),
+		partSize:          cmp.Or(cfg.PartSize, defaultPartSize),
+		uploadConcurrency: cmp.Or(max(cfg.UploadConcurrency, 0), defaultUploadConcurrency),
+		readChunkSize:     cmp.Or(max(cfg.ReadChunkSize, 0), defaultReadChunkSize),
+		readConcurrency:   cmp.Or(max(cfg.ReadConcurrency, 0), defaultReadConcurrency),
+		put: minio.PutObjectOptions{
+			ServerSideEncryption: sse,
+			StorageClass:         cfg.StorageClass,
+			UserMetadata:         cfg.Metadata,
+			UserTags:             cfg.Tags,
+		},
+	}
+	if cfg.SSE == SSECustomer {
+		s.ssec = sse
+	}
+	return s, nil
+}
+
+// serverSide builds the encryption of cfg.SSE, nil for SSENone.
+func serverSide(cfg Config) (encrypt.ServerSide, error) {
+	if cfg.SSE != SSEKMS && cfg.KMSKeyID != "" {
+		return nil, fmt.Errorf("kms key id requires sse %q", SSEKMS)
+	}
+	if cfg.SSE != SSECustomer && cfg.CustomerKey != nil {
+		return nil, fmt.Errorf("customer key requires sse %q", SSECustomer)
+	}
+
+	switch cfg.SSE {
+	case SSENone:
+		return nil, nil
+	case SSES3:
+		return encrypt.NewSSE(), nil
+	case SSEKMS:
+		sse, err := encrypt.NewSSEKMS(cfg.KMSKeyID, nil)
+		if err != nil {
+			return nil, fmt.Errorf("sse-kms: %w", err)
+		}
+		return sse, nil
+	case SSECustomer:
+		sse, err := encrypt.NewSSEC(cfg.CustomerKey)
+		if err != nil {
+			return nil, fmt.Errorf("sse-c: %w", err)
+		}
+		return sse, nil
+	}
+	return nil, fmt.Errorf("invalid sse %q: must be %s, %s or %s", cfg.SSE, SSES3, SSEKMS, SSECustomer)
 }
 
 func (s *Store) key(name string) string {
@@ -72,49 +215,126 @@ func (s *Store) key(name string) string {
 	return s.prefix + "/" + name
 }
 
-// Open returns a reader for the named object.
+// name is the inverse of key.
+func (s *Store) name(key string) string {
+	if s.prefix == "" {
+		return key
+	}
+	return strings.TrimPrefix(key, s.prefix+"/")
+}
+
+// Open returns a reader for the named object. Objects larger than one read
+// chunk are fetched as parallel byte ranges, see Config.ReadChunkSize.
 func (s *Store) Open(name string) (io.ReadCloser, error) {
-	obj, err := s.client.GetObject(s.ctx, s.bucket, s.key(name), minio.GetObjectOptions{})
+	key := s.key(name)
+	var info minio.ObjectInfo
+	err := s.retry.do(s.ctx, func() (err error) {
+		info, err = s.client.StatObject(s.ctx, s.bucket, key, minio.StatObjectOptions{ServerSideEncryption: s.ssec})
+		return err
+	})
 	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, fmt.Errorf("open %s: %w (%w)", name, fs.ErrNotExist, err)
+		}
 		return nil, fmt.Errorf("open %s: %w", name, err)
 	}
-	// GetObject is lazy; probe so a missing key fails here, not on first Read.
-	if _, err := obj.Stat(); err != nil {
-		obj.Close()
+
+	if info.Size > s.readChunkSize && s.readConcurrency > 1 {
+		return newRangeReader(s, key, info), nil
+	}
+	if info.Size <= s.readChunkSize {
+		if info.Size == 0 {
+			return io.NopCloser(bytes.NewReader(nil)), nil
+		}
+		data, err := s.getRange(s.ctx, key, info.ETag, 0, info.Size-1)
+		if err != nil {
+			return nil, fmt.Errorf("open %s: %w", name, err)
+		}
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+
+	// A single stream; only opening it is retried
+	var body io.ReadCloser
+	err = s.retry.do(s.ctx, func() (err error) {
+		body, _, _, err = s.core.GetObject(s.ctx, s.bucket, key, minio.GetObjectOptions{ServerSideEncryption: s.ssec})
+		return err
+	})
+	if err != nil {
 		return nil, fmt.Errorf("open %s: %w", name, err)
 	}
-	return obj, nil
+	return body, nil
 }
 
-// Create returns a writer that streams to the named object and finalises the
-// upload on Close. The write is only durable once Close returns without error.
-func (s *Store) Create(name string) (io.WriteCloser, error) {
-	pr, pw := io.Pipe()
-	w := &objectWriter{pw: pw, done: make(chan error, 1)}
-
-	go func() {
-		// size -1 streams the body via multipart upload without a known length.
-		_, err := s.client.PutObject(s.ctx, s.bucket, s.key(name), pr, -1, minio.PutObjectOptions{})
-		pr.CloseWithError(err)
-		w.done <- err
-	}()
-
-	return w, nil
+// List returns the names of all objects under the store prefix that start
+// with prefix, sorted.
+func (s *Store) List(prefix string) ([]string, error) {
+	var names []string
+	err := s.retry.do(s.ctx, func() error {
+		names = names[:0]
+		for obj := range s.client.ListObjects(s.ctx, s.bucket, minio.ListObjectsOptions{Prefix: s.key(prefix), Recursive: true}) {
+			if obj.Err != nil {
+				return obj.Err
+			}
+			names = append(names, s.name(obj.Key))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %w", prefix, err)
+	}
+	slices.Sort(names)
+	return names, nil
 }
 
-// objectWriter feeds an io.Pipe whose read end is consumed by PutObject.
-type objectWriter struct {
-	pw   *io.PipeWriter
-	done chan error
+// Stat returns the size and last modification time of the named object.
+func (s *Store) Stat(name string) (fs.FileInfo, error) {
+	var info minio.ObjectInfo
+	err := s.retry.do(s.ctx, func() (err error) {
+		info, err = s.client.StatObject(s.ctx, s.bucket, s.key(name), minio.StatObjectOptions{ServerSideEncryption: s.ssec})
+		return err
+	})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, fmt.Errorf("stat %s: %w (%w)", name, fs.ErrNotExist, err)
+		}
+		return nil, fmt.Errorf("stat %s: %w", name, err)
+	}
+	return objectInfo{name: name, info: info}, nil
 }
 
-func (w *objectWriter) Write(p []byte) (int, error) {
-	return w.pw.Write(p)
+// objectInfo adapts minio.ObjectInfo to fs.FileInfo.
+type objectInfo struct {
+	name string
+	info minio.ObjectInfo
 }
 
-func (w *objectWriter) Close() error {
-	if err := w.pw.Close(); err != nil {
-		return err
+func (oi objectInfo) Name() string       { return oi.name }
+func (oi objectInfo) Size() int64        { return oi.info.Size }
+func (oi objectInfo) Mode() fs.FileMode  { return 0o644 }
+func (oi objectInfo) ModTime() time.Time { return oi.info.LastModified }
+func (oi objectInfo) IsDir() bool        { return false }
+func (oi objectInfo) Sys() any           { return oi.info }
+
+// Delete removes the named object. S3 treats deleting a missing key as a
+// success.
+func (s *Store) Delete(name string) error {
+	err := s.retry.do(s.ctx, func() error {
+		return s.client.RemoveObject(s.ctx, s.bucket, s.key(name), minio.RemoveObjectOptions{})
+	})
+	if err != nil {
+		return fmt.Errorf("delete %s: %w", name, err)
 	}
-	return <-w.done
+	return nil
+}
+
+// Create returns a writer that uploads to the named object in parts of
+// Config.PartSize, several at once, and completes the upload on Close. The
+// write is only durable once Close returns without error.
+func (s *Store) Create(name string) (io.WriteCloser, error) {
+	return &objectWriter{
+		s:   s,
+		key: s.key(name),
+		buf: make([]byte, 0, s.partSize),
+		sem: make(chan struct{}, s.uploadConcurrency),
+	}, nil
 }
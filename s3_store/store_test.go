@@ -0,0 +1,95 @@
+package s3_store
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+)
+
+func Test_serverSide(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	tests := []struct {
+		name    string
+		cfg     Config
+		want    encrypt.Type
+		wantErr bool
+	}{
+		{name: "none", cfg: Config{}},
+		{name: "s3", cfg: Config{SSE: SSES3}, want: encrypt.S3},
+		{name: "kms default key", cfg: Config{SSE: SSEKMS}, want: encrypt.KMS},
+		{name: "kms key id", cfg: Config{SSE: SSEKMS, KMSKeyID: "arn:aws:kms:eu-west-1:1:key/abc"}, want: encrypt.KMS},
+		{name: "customer", cfg: Config{SSE: SSECustomer, CustomerKey: key}, want: encrypt.SSEC},
+		{name: "customer short key", cfg: Config{SSE: SSECustomer, CustomerKey: key[:16]}, wantErr: true},
+		{name: "kms key without kms", cfg: Config{SSE: SSES3, KMSKeyID: "abc"}, wantErr: true},
+		{name: "customer key without customer", cfg: Config{CustomerKey: key}, wantErr: true},
+		{name: "unknown", cfg: Config{SSE: "aes"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sse, err := serverSide(tt.cfg)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == "" {
+				if sse != nil {
+					t.Fatalf("got %v, want no encryption", sse.Type())
+				}
+				return
+			}
+			if sse == nil || sse.Type() != tt.want {
+				t.Fatalf("got %v, want %v", sse, tt.want)
+			}
+		})
+	}
+}
+
+func TestStore_partSizeFor(t *testing.T) {
+	s := &Store{partSize: minPartSize}
+	tests := []struct {
+		part int
+		want int64
+	}{
+		{part: 1, want: minPartSize},
+		{part: 1000, want: minPartSize},
+		{part: 1001, want: 2 * minPartSize},
+		{part: 2001, want: 4 * minPartSize},
+		{part: maxParts, want: 512 * minPartSize},
+	}
+	for _, tt := range tests {
+		if got := s.partSizeFor(tt.part); got != tt.want {
+			t.Errorf("part %d: got %d, want %d", tt.part, got, tt.want)
+		}
+	}
+
+	var total int64
+	for n := 1; n <= maxParts; n++ {
+		total += s.partSizeFor(n)
+	}
+	if total < 4<<40 {
+		t.Errorf("10,000 parts hold only %d bytes", total)
+	}
+
+	big := &Store{partSize: 1 << 30}
+	if got := big.partSizeFor(maxParts); got != maxPartSize {
+		t.Errorf("grown part size %d exceeds the %d maximum", got, maxPartSize)
+	}
+}
+
+func TestObjectWriter_TooManyParts(t *testing.T) {
+	w := &objectWriter{s: &Store{partSize: minPartSize}, nextPart: maxParts, uploadID: "upload"}
+	err := w.uploadPart()
+	if err == nil || !strings.Contains(err.Error(), "limit of 10000 parts") {
+		t.Fatalf("got %v", err)
+	}
+	if _, err := w.Write([]byte("more")); err == nil {
+		t.Fatal("write after the limit should fail")
+	}
+}
@@ -0,0 +1,178 @@
+package s3_store
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// S3 limits of a multipart upload.
+const (
+	maxParts    = 10_000
+	maxPartSize = 5 << 30
+	// partsPerSize parts are uploaded at each part size before it doubles.
+	partsPerSize = 1000
+)
+
+// partSizeFor returns the size of part number n (1 based): Config.PartSize
+// for the first 1000 parts, then doubling every 1000 parts up to 5 GiB, so
+// that objects of unknown size can reach the 5 TiB object limit within
+// 10,000 parts.
+func (s *Store) partSizeFor(n int) int64 {
+	size := s.partSize
+	for range (n - 1) / partsPerSize {
+		if size >= maxPartSize/2 {
+			return maxPartSize
+		}
+		size *= 2
+	}
+	return size
+}
+
+// objectWriter uploads an object in parts, up to concurrency at a time,
+// starting the multipart upload once the first part is full. Objects smaller
+// than one part are sent with a single PutObject on Close.
+type objectWriter struct {
+	s   *Store
+	key string
+
+	buf      []byte // the part being filled
+	uploadID string
+	nextPart int
+	sem      chan struct{} // bounds the parts in flight
+	wg       sync.WaitGroup
+
+	mu    sync.Mutex
+	parts []minio.CompletePart
+	err   error // first failed part
+}
+
+func (w *objectWriter) Write(p []byte) (int, error) {
+	if err := w.failed(); err != nil {
+		return 0, err
+	}
+	n := len(p)
+	for len(p) > 0 {
+		size := int(w.s.partSizeFor(w.nextPart + 1))
+		free := min(size-len(w.buf), len(p))
+		w.buf = append(w.buf, p[:free]...)
+		p = p[free:]
+		if len(w.buf) == size {
+			if err := w.uploadPart(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// uploadPart hands the full buffer to a goroutine, blocking while
+// concurrency parts are already in flight.
+func (w *objectWriter) uploadPart() error {
+	if w.nextPart == maxParts {
+		return w.fail(fmt.Errorf("object exceeds the S3 limit of %d parts (%d bytes uploaded); raise the part size", maxParts, w.uploaded()))
+	}
+	if w.uploadID == "" {
+		err := w.s.retry.do(w.s.ctx, func() (err error) {
+			w.uploadID, err = w.s.core.NewMultipartUpload(w.s.ctx, w.s.bucket, w.key, w.s.put)
+			return err
+		})
+		if err != nil {
+			return w.fail(fmt.Errorf("start multipart upload: %w", err))
+		}
+	}
+
+	w.nextPart++
+	part, data := w.nextPart, w.buf
+	w.buf = make([]byte, 0, w.s.partSizeFor(part+1))
+
+	select {
+	case w.sem <- struct{}{}:
+	case <-w.s.ctx.Done():
+		return w.fail(w.s.ctx.Err())
+	}
+	w.wg.Add(1)
+	go func() {
+		defer func() {
+			<-w.sem
+			w.wg.Done()
+		}()
+
+		var uploaded minio.ObjectPart
+		err := w.s.retry.do(w.s.ctx, func() (err error) {
+			uploaded, err = w.s.core.PutObjectPart(w.s.ctx, w.s.bucket, w.key, w.uploadID, part,
+				bytes.NewReader(data), int64(len(data)), minio.PutObjectPartOptions{SSE: w.s.ssec})
+			return err
+		})
+
+		if err != nil {
+			w.fail(fmt.Errorf("upload part %d: %w", part, err))
+			return
+		}
+		w.mu.Lock()
+		w.parts = append(w.parts, minio.CompletePart{PartNumber: part, ETag: uploaded.ETag})
+		w.mu.Unlock()
+	}()
+	return w.failed()
+}
+
+// uploaded returns the size of the parts handed out so far.
+func (w *objectWriter) uploaded() int64 {
+	var total int64
+	for n := 1; n <= w.nextPart; n++ {
+		total += w.s.partSizeFor(n)
+	}
+	return total
+}
+
+// fail records err unless an earlier error is recorded, returning the first.
+func (w *objectWriter) fail(err error) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err == nil {
+		w.err = err
+	}
+	return w.err
+}
+
+func (w *objectWriter) failed() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Close uploads what is left and completes the upload. A failed multipart
+// upload is aborted so its parts do not linger in the bucket.
+func (w *objectWriter) Close() error {
+	if w.uploadID == "" {
+		if err := w.failed(); err != nil {
+			return err
+		}
+		return w.s.retry.do(w.s.ctx, func() error {
+			_, err := w.s.core.PutObject(w.s.ctx, w.s.bucket, w.key, bytes.NewReader(w.buf), int64(len(w.buf)), "", "", w.s.put)
+			return err
+		})
+	}
+
+	err := w.failed()
+	if err == nil && len(w.buf) > 0 {
+		err = w.uploadPart()
+	}
+	w.wg.Wait()
+	if err == nil {
+		err = w.failed()
+	}
+	if err != nil {
+		w.s.core.AbortMultipartUpload(w.s.ctx, w.s.bucket, w.key, w.uploadID)
+		return err
+	}
+
+	slices.SortFunc(w.parts, func(a, b minio.CompletePart) int { return a.PartNumber - b.PartNumber })
+	return w.s.retry.do(w.s.ctx, func() error {
+		_, err := w.s.core.CompleteMultipartUpload(w.s.ctx, w.s.bucket, w.key, w.uploadID, w.parts, w.s.put)
+		return err
+	})
+}
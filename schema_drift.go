@@ -0,0 +1,238 @@
+package pg_mini
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// DriftPolicy controls what Import does when the schema stored in the backup
+// differs from the schema of the target database.
+type DriftPolicy string
+
+const (
+	// DriftIgnore skips the pre-flight comparison entirely (default).
+	DriftIgnore DriftPolicy = ""
+	// DriftFail aborts the import before any data is written.
+	DriftFail DriftPolicy = "fail"
+	// DriftWarn logs every difference and imports as usual.
+	DriftWarn DriftPolicy = "warn"
+	// DriftAdapt logs every difference and imports only the tables and
+	// columns that exist in both the backup and the target.
+	DriftAdapt DriftPolicy = "adapt"
+)
+
+func (p DriftPolicy) valid() bool {
+	switch p {
+	case DriftIgnore, DriftFail, DriftWarn, DriftAdapt:
+		return true
+	}
+	return false
+}
+
+// schemaDiff lists the differences between a backup schema and a target
+// schema, restricted to the tables being imported.
+type schemaDiff struct {
+	MissingTables []string // in the backup, not in the target
+	ExtraTables   []string // in the target, not in the backup
+	Tables        []tableDiff
+}
+
+type tableDiff struct {
+	Table             string
+	MissingCols       []string // in the backup, not in the target
+	ExtraCols         []string // in the target, not in the backup
+	TypeChanges       []columnTypeChange
+	NotNullAdded      []string // nullable in the backup, NOT NULL in the target
+	PrimaryKeyChanged bool
+	BackupPrimaryKey  []string
+	TargetPrimaryKey  []string
+	MissingUniques    [][]string // unique constraints in the backup, not in the target
+	ExtraUniques      [][]string // unique constraints in the target, not in the backup
+}
+
+type columnTypeChange struct {
+	Column     string
+	BackupType string
+	TargetType string
+}
+
+func (d *schemaDiff) empty() bool {
+	return len(d.MissingTables) == 0 && len(d.ExtraTables) == 0 && len(d.Tables) == 0
+}
+
+// affectsImport reports whether any imported table differs. Tables that only
+// exist in the target are reported but never block an import.
+func (d *schemaDiff) affectsImport() bool {
+	return len(d.MissingTables) > 0 || len(d.Tables) > 0
+}
+
+func (d tableDiff) empty() bool {
+	return len(d.MissingCols) == 0 && len(d.ExtraCols) == 0 && len(d.TypeChanges) == 0 &&
+		len(d.NotNullAdded) == 0 && !d.PrimaryKeyChanged && len(d.MissingUniques) == 0 && len(d.ExtraUniques) == 0
+}
+
+// diffSchemas compares the backup schema against the target schema for the
+// given tables. Generated columns are ignored since they are never imported.
+func diffSchemas(backup, target *Schema, tables []string) *schemaDiff {
+	diff := &schemaDiff{}
+
+	for _, tbl := range tables {
+		backupTbl := backup.Tables[tbl]
+		targetTbl, ok := target.Tables[tbl]
+		if !ok {
+			diff.MissingTables = append(diff.MissingTables, tbl)
+			continue
+		}
+
+		td := tableDiff{Table: tbl}
+
+		targetCols := map[string]columnSchema{}
+		for _, col := range targetTbl.Cols {
+			if !col.Generated {
+				targetCols[col.Name] = col
+			}
+		}
+		backupCols := map[string]bool{}
+		for _, col := range backupTbl.Cols {
+			if col.Generated {
+				continue
+			}
+			backupCols[col.Name] = true
+
+			targetCol, ok := targetCols[col.Name]
+			if !ok {
+				td.MissingCols = append(td.MissingCols, col.Name)
+				continue
+			}
+			// Backups taken before column types were recorded have no Type,
+			// and before full column metadata was recorded no FullType.
+			// Comparing FullType also catches changed lengths and precision.
+			hasMeta := col.FullType != "" && targetCol.FullType != ""
+			switch {
+			case hasMeta && col.FullType != targetCol.FullType:
+				td.TypeChanges = append(td.TypeChanges, columnTypeChange{
+					Column:     col.Name,
+					BackupType: col.FullType,
+					TargetType: targetCol.FullType,
+				})
+			case !hasMeta && col.Type != "" && targetCol.Type != "" && col.Type != targetCol.Type:
+				td.TypeChanges = append(td.TypeChanges, columnTypeChange{
+					Column:     col.Name,
+					BackupType: col.Type,
+					TargetType: targetCol.Type,
+				})
+			}
+			if hasMeta && col.Nullable && !targetCol.Nullable {
+				td.NotNullAdded = append(td.NotNullAdded, col.Name)
+			}
+		}
+		for _, col := range targetTbl.Cols {
+			if !col.Generated && !backupCols[col.Name] {
+				td.ExtraCols = append(td.ExtraCols, col.Name)
+			}
+		}
+
+		if !slices.Equal(backupTbl.PrimaryKeyCols, targetTbl.PrimaryKeyCols) {
+			td.PrimaryKeyChanged = true
+			td.BackupPrimaryKey = backupTbl.PrimaryKeyCols
+			td.TargetPrimaryKey = targetTbl.PrimaryKeyCols
+		}
+
+		td.MissingUniques = uniquesNotIn(backupTbl.UniqueConstraints, targetTbl.UniqueConstraints)
+		td.ExtraUniques = uniquesNotIn(targetTbl.UniqueConstraints, backupTbl.UniqueConstraints)
+
+		if !td.empty() {
+			diff.Tables = append(diff.Tables, td)
+		}
+	}
+
+	for tbl := range target.Tables {
+		if _, ok := backup.Tables[tbl]; !ok {
+			diff.ExtraTables = append(diff.ExtraTables, tbl)
+		}
+	}
+	slices.Sort(diff.ExtraTables)
+
+	return diff
+}
+
+// uniquesNotIn returns the constraints in a that have no equivalent in b.
+// Column order within a constraint is not significant.
+func uniquesNotIn(a, b [][]string) [][]string {
+	key := func(cols []string) string {
+		sorted := slices.Clone(cols)
+		slices.Sort(sorted)
+		return strings.Join(sorted, ",")
+	}
+
+	inB := map[string]bool{}
+	for _, cols := range b {
+		inB[key(cols)] = true
+	}
+
+	var result [][]string
+	for _, cols := range a {
+		if !inB[key(cols)] {
+			result = append(result, cols)
+		}
+	}
+	return result
+}
+
+// log reports every difference through slog at warn level.
+func (d *schemaDiff) log() {
+	for _, tbl := range d.MissingTables {
+		slog.Warn("Schema drift: table missing in target", "table", tbl)
+	}
+	for _, tbl := range d.ExtraTables {
+		slog.Warn("Schema drift: table not in backup", "table", tbl)
+	}
+	for _, td := range d.Tables {
+		for _, col := range td.MissingCols {
+			slog.Warn("Schema drift: column missing in target", "table", td.Table, "column", col)
+		}
+		for _, col := range td.ExtraCols {
+			slog.Warn("Schema drift: column not in backup", "table", td.Table, "column", col)
+		}
+		for _, tc := range td.TypeChanges {
+			slog.Warn("Schema drift: column type changed", "table", td.Table, "column", tc.Column,
+				"backup", tc.BackupType,
+				"target", tc.TargetType,
+			)
+		}
+		for _, col := range td.NotNullAdded {
+			slog.Warn("Schema drift: column is NOT NULL in target", "table", td.Table, "column", col)
+		}
+		if td.PrimaryKeyChanged {
+			slog.Warn("Schema drift: primary key changed", "table", td.Table,
+				"backup", strings.Join(td.BackupPrimaryKey, ", "),
+				"target", strings.Join(td.TargetPrimaryKey, ", "),
+			)
+		}
+		for _, cols := range td.MissingUniques {
+			slog.Warn("Schema drift: unique constraint missing in target", "table", td.Table, "columns", strings.Join(cols, ", "))
+		}
+		for _, cols := range td.ExtraUniques {
+			slog.Warn("Schema drift: unique constraint not in backup", "table", td.Table, "columns", strings.Join(cols, ", "))
+		}
+	}
+}
+
+// summary is a one-line description of the differences that affect the
+// import, used in error messages.
+func (d *schemaDiff) summary() string {
+	var parts []string
+	if len(d.MissingTables) > 0 {
+		parts = append(parts, fmt.Sprintf("tables missing in target: %s", strings.Join(d.MissingTables, ", ")))
+	}
+	if len(d.Tables) > 0 {
+		var tbls []string
+		for _, td := range d.Tables {
+			tbls = append(tbls, td.Table)
+		}
+		parts = append(parts, fmt.Sprintf("tables with column or constraint changes: %s", strings.Join(tbls, ", ")))
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,173 @@
+package pg_mini
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func driftTestSchemas() (backup, target *Schema) {
+	backup = &Schema{
+		Tables: map[string]tableSchema{
+			"account": {
+				Name: "account",
+				Cols: []columnSchema{
+					{Name: "id", Type: "int4"},
+					{Name: "fullname", Type: "text"},
+					{Name: "score", Type: "int4"},
+				},
+				PrimaryKeyCols: []string{"id"},
+			},
+			"invoice": {
+				Name: "invoice",
+				Cols: []columnSchema{
+					{Name: "id", Type: "int4"},
+					{Name: "account_id", Type: "int4"},
+				},
+				PrimaryKeyCols: []string{"id"},
+			},
+		},
+		Relations: []foreignKeyRelation{
+			{FromTable: "invoice", FromColumn: "account_id", ToTable: "account", ToColumn: "id"},
+		},
+	}
+	target = &Schema{
+		Tables: map[string]tableSchema{
+			"account": {
+				Name: "account",
+				Cols: []columnSchema{
+					{Name: "id", Type: "int4"},
+					{Name: "first_name", Type: "text"},
+					{Name: "score", Type: "numeric"},
+				},
+				PrimaryKeyCols:    []string{"id"},
+				UniqueConstraints: [][]string{{"first_name"}},
+			},
+			"audit_log": {
+				Name: "audit_log",
+				Cols: []columnSchema{{Name: "id", Type: "int4"}},
+			},
+		},
+	}
+	return backup, target
+}
+
+func Test_diffSchemas(t *testing.T) {
+	backup, target := driftTestSchemas()
+
+	diff := diffSchemas(backup, target, []string{"account", "invoice"})
+
+	want := &schemaDiff{
+		MissingTables: []string{"invoice"},
+		ExtraTables:   []string{"audit_log"},
+		Tables: []tableDiff{
+			{
+				Table:        "account",
+				MissingCols:  []string{"fullname"},
+				ExtraCols:    []string{"first_name"},
+				TypeChanges:  []columnTypeChange{{Column: "score", BackupType: "int4", TargetType: "numeric"}},
+				ExtraUniques: [][]string{{"first_name"}},
+			},
+		},
+	}
+	if d := deep.Equal(diff, want); d != nil {
+		for _, line := range d {
+			t.Error(line)
+		}
+	}
+
+	if !diff.affectsImport() {
+		t.Error("expected diff to affect import")
+	}
+}
+
+func Test_diffSchemas_NoDrift(t *testing.T) {
+	backup, _ := driftTestSchemas()
+
+	diff := diffSchemas(backup, backup, []string{"account", "invoice"})
+	if !diff.empty() {
+		t.Errorf("expected empty diff, got %+v", diff)
+	}
+}
+
+func Test_generateImportQueries_Adapt(t *testing.T) {
+	backup, target := driftTestSchemas()
+
+	graph, err := buildGraph(backup, "account")
+	if err != nil {
+		t.Fatalf("buildGraph: %v", err)
+	}
+
+	queries := generateImportQueries(graph, backup, importQueryOpts{Target: target})
+	if len(queries) != 1 {
+		t.Fatalf("want 1 table (invoice missing in target), got %d", len(queries))
+	}
+
+	tq := queries[0]
+	if tq.Copy != "" {
+		t.Errorf("expected no direct COPY when the CSV has columns the target lacks, got %q", tq.Copy)
+	}
+	if d := deep.Equal(tq.Columns, []string{"id", "score"}); d != nil {
+		t.Errorf("columns: %v", d)
+	}
+	if !strings.Contains(tq.CreateTemp, "INCLUDING ALL, fullname text)") {
+		t.Errorf("temp table should carry dropped column as text: %s", tq.CreateTemp)
+	}
+	if !strings.Contains(tq.CopyTemp, "(id, fullname, score)") {
+		t.Errorf("temp COPY should list every CSV column: %s", tq.CopyTemp)
+	}
+	want := "INSERT INTO account (id, score) OVERRIDING SYSTEM VALUE SELECT id, score FROM tmp_import_account;"
+	if tq.InsertTemp != want {
+		t.Errorf("InsertTemp:\n  want: %s\n  got:  %s", want, tq.InsertTemp)
+	}
+}
+
+func Test_diffSchemas_ColumnMetadata(t *testing.T) {
+	backup := &Schema{
+		Tables: map[string]tableSchema{
+			"account": {
+				Name: "account",
+				Cols: []columnSchema{
+					{Name: "id", Type: "int4", FullType: "integer", Identity: "always"},
+					{Name: "email", Type: "varchar", FullType: "character varying(64)", Nullable: true},
+					{Name: "note", Type: "text", FullType: "text", Nullable: true},
+					{Name: "legacy", Type: "text", Nullable: true},
+				},
+			},
+		},
+	}
+	target := &Schema{
+		Tables: map[string]tableSchema{
+			"account": {
+				Name: "account",
+				Cols: []columnSchema{
+					{Name: "id", Type: "int4", FullType: "integer", Identity: "by default"},
+					{Name: "email", Type: "varchar", FullType: "character varying(32)", Nullable: true},
+					{Name: "note", Type: "text", FullType: "text", Default: "''::text"},
+					// no FullType on the backup side: only Type is compared
+					{Name: "legacy", Type: "text", FullType: "text"},
+				},
+			},
+		},
+	}
+
+	diff := diffSchemas(backup, target, []string{"account"})
+
+	want := &schemaDiff{
+		Tables: []tableDiff{
+			{
+				Table: "account",
+				TypeChanges: []columnTypeChange{
+					{Column: "email", BackupType: "character varying(64)", TargetType: "character varying(32)"},
+				},
+				NotNullAdded: []string{"note"},
+			},
+		},
+	}
+	if d := deep.Equal(diff, want); d != nil {
+		for _, line := range d {
+			t.Error(line)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package pg_mini
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ScriptMode controls whether and how Export renders the subset as a plain
+// SQL script, data.sql, restorable with psql or a migration runner.
+type ScriptMode string
+
+const (
+	// ScriptNone writes no script (default).
+	ScriptNone ScriptMode = ""
+	// ScriptCopy writes COPY ... FROM stdin blocks, for psql.
+	ScriptCopy ScriptMode = "copy"
+	// ScriptInsert writes batched INSERT statements, for tools that cannot
+	// feed COPY data.
+	ScriptInsert ScriptMode = "insert"
+)
+
+func (m ScriptMode) valid() bool {
+	switch m {
+	case ScriptNone, ScriptCopy, ScriptInsert:
+		return true
+	}
+	return false
+}
+
+// sqlScriptName is the Store entry holding the SQL script.
+const sqlScriptName = "data.sql"
+
+// scriptInsertBatch is the number of rows per INSERT statement.
+const scriptInsertBatch = 1000
+
+// writeSQLScript renders the subset held in the export temp tables as one
+// SQL script: the tables in import order inside a transaction, followed by
+// setval calls moving sequences past the imported keys.
+func writeSQLScript(ctx context.Context, conn *pgx.Conn, store Store, g *Graph, schema *Schema, mode ScriptMode, progress func(int64)) (*copyOutRes, error) {
+	f, err := store.Create(sqlScriptName)
+	if err != nil {
+		return nil, fmt.Errorf("creating file: %w", err)
+	}
+	closed := false
+	defer func() {
+		if !closed {
+			f.Close()
+		}
+	}()
+
+	cw := &countingWriter{w: f, progress: progress}
+	w := bufio.NewWriterSize(cw, 1024*1024)
+
+	fmt.Fprintf(w, "-- pg_mini export of %s\n", g.RootTbl)
+	fmt.Fprintf(w, "-- restore with: psql -v ON_ERROR_STOP=1 -f %s\n\n", sqlScriptName)
+	w.WriteString("SET client_encoding = 'UTF8';\n")
+	w.WriteString("SET standard_conforming_strings = on;\n\n")
+	w.WriteString("BEGIN;\n\n")
+
+	start := time.Now()
+	var rows int64
+	for _, tbl := range g.ImportOrder {
+		cols := strings.Join(g.Tables[tbl].IncludeCols, ", ")
+
+		var n int64
+		if mode == ScriptInsert {
+			n, err = writeScriptInserts(ctx, conn, w, tbl, g.Tables[tbl].IncludeCols)
+		} else {
+			fmt.Fprintf(w, "COPY %s (%s) FROM stdin;\n", tbl, cols)
+			var tag pgconn.CommandTag
+			tag, err = conn.PgConn().CopyTo(ctx, w, fmt.Sprintf("COPY %s TO STDOUT;", tmpTblName(tbl)))
+			n = tag.RowsAffected()
+			w.WriteString("\\.\n\n")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("writing %s: %w", tbl, err)
+		}
+		rows += n
+	}
+
+	for _, stmt := range scriptSetvals(g, schema) {
+		w.WriteString(stmt + "\n")
+	}
+
+	w.WriteString("\nCOMMIT;\n")
+	if err := w.Flush(); err != nil {
+		return nil, fmt.Errorf("flushing data: %w", err)
+	}
+
+	closed = true
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("closing file: %w", err)
+	}
+
+	return &copyOutRes{
+		FileName: sqlScriptName,
+		Rows:     rows,
+		Duration: time.Since(start),
+		FileSize: cw.n,
+	}, nil
+}
+
+// scriptSetvals returns a setval call for every serial or identity column
+// of the exported tables, moving its sequence to the column's maximum on the
+// restored database. Tables left empty keep their sequence as is.
+func scriptSetvals(g *Graph, schema *Schema) []string {
+	var stmts []string
+	for _, tbl := range g.ImportOrder {
+		for _, col := range schema.Tables[tbl].Cols {
+			if col.Identity == "" && !strings.HasPrefix(col.Default, "nextval(") {
+				continue
+			}
+			stmts = append(stmts, fmt.Sprintf(
+				"SELECT setval(pg_get_serial_sequence('%s', '%s'), max(%s)) FROM %s HAVING max(%s) IS NOT NULL;",
+				tbl, col.Name, col.Name, tbl, col.Name,
+			))
+		}
+	}
+	return stmts
+}
+
+// writeScriptInserts writes the rows of tbl's temp table as INSERT
+// statements of up to scriptInsertBatch rows. quote_nullable renders each
+// value as a literal the target casts back to the column type.
+func writeScriptInserts(ctx context.Context, conn *pgx.Conn, w *bufio.Writer, tbl string, cols []string) (int64, error) {
+	literals := make([]string, len(cols))
+	for idx, col := range cols {
+		literals[idx] = fmt.Sprintf("quote_nullable(%s)", col)
+	}
+	query := fmt.Sprintf("SELECT concat_ws(', ', %s) FROM %s;", strings.Join(literals, ", "), tmpTblName(tbl))
+
+	rows, err := conn.Query(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var n int64
+	for rows.Next() {
+		var values string
+		if err := rows.Scan(&values); err != nil {
+			return n, err
+		}
+		if n%scriptInsertBatch == 0 {
+			if n > 0 {
+				w.WriteString(";\n")
+			}
+			fmt.Fprintf(w, "INSERT INTO %s (%s) OVERRIDING SYSTEM VALUE VALUES\n", tbl, strings.Join(cols, ", "))
+		} else {
+			w.WriteString(",\n")
+		}
+		w.WriteString("(" + values + ")")
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return n, err
+	}
+	if n > 0 {
+		w.WriteString(";\n")
+	}
+	w.WriteString("\n")
+	return n, nil
+}
@@ -0,0 +1,32 @@
+package pg_mini
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func Test_scriptSetvals(t *testing.T) {
+	schema, _ := driftTestSchemas()
+	account := schema.Tables["account"]
+	account.Cols[0].Identity = "by default"
+	schema.Tables["account"] = account
+	invoice := schema.Tables["invoice"]
+	invoice.Cols[0].Default = "nextval('invoice_id_seq'::regclass)"
+	schema.Tables["invoice"] = invoice
+
+	graph, err := buildGraph(schema, "account")
+	if err != nil {
+		t.Fatalf("buildGraph: %v", err)
+	}
+
+	want := []string{
+		"SELECT setval(pg_get_serial_sequence('account', 'id'), max(id)) FROM account HAVING max(id) IS NOT NULL;",
+		"SELECT setval(pg_get_serial_sequence('invoice', 'id'), max(id)) FROM invoice HAVING max(id) IS NOT NULL;",
+	}
+	if d := deep.Equal(scriptSetvals(graph, schema), want); d != nil {
+		for _, line := range d {
+			t.Error(line)
+		}
+	}
+}
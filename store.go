@@ -2,10 +2,14 @@ package pg_mini
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 )
 
 // Store abstracts where pg_mini reads and writes its export artifacts
@@ -20,7 +24,22 @@ type Store interface {
 	Open(name string) (io.ReadCloser, error)
 }
 
-// DirStore returns a Store backed by a local directory.
+// ExtendedStore is implemented by Stores that can also enumerate, inspect
+// and remove entries. pg_mini detects it by type assertion; Export uses it to
+// remove files left over from a previous export to the same location.
+type ExtendedStore interface {
+	Store
+	// List returns the names of all entries starting with prefix, sorted.
+	List(prefix string) ([]string, error)
+	// Stat describes name; Size and ModTime are the fields pg_mini relies
+	// on. The error for a missing name should wrap fs.ErrNotExist.
+	Stat(name string) (fs.FileInfo, error)
+	// Delete removes name. Deleting a missing name is not an error.
+	Delete(name string) error
+}
+
+// DirStore returns a Store backed by a local directory. It implements
+// ExtendedStore.
 func DirStore(dir string) Store {
 	return dirStore{dir: dir}
 }
@@ -41,6 +60,72 @@ func (d dirStore) Open(name string) (io.ReadCloser, error) {
 	return os.Open(filepath.Join(d.dir, name))
 }
 
+func (d dirStore) List(prefix string) ([]string, error) {
+	var names []string
+	err := filepath.WalkDir(d.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == d.dir && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(d.dir, path)
+		if err != nil {
+			return err
+		}
+		if name := filepath.ToSlash(rel); strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %w", d.dir, err)
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+func (d dirStore) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(filepath.Join(d.dir, name))
+}
+
+func (d dirStore) Delete(name string) error {
+	err := os.Remove(filepath.Join(d.dir, name))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// OverlayStore returns a Store that writes to upper and reads from upper,
+// falling back to base for names upper does not have. It lets an import read
+// a backup it must not modify, such as an archive, while its reports and
+// rejected rows go to upper. upper must report missing names with an error
+// wrapping fs.ErrNotExist, as DirStore and MemStore do.
+func OverlayStore(base, upper Store) Store {
+	return overlayStore{base: base, upper: upper}
+}
+
+type overlayStore struct {
+	base  Store
+	upper Store
+}
+
+func (o overlayStore) Create(name string) (io.WriteCloser, error) {
+	return o.upper.Create(name)
+}
+
+func (o overlayStore) Open(name string) (io.ReadCloser, error) {
+	r, err := o.upper.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return o.base.Open(name)
+	}
+	return r, err
+}
+
 // saveJSON encodes v as indented JSON into name, surfacing any Close error
 // (backends like S3 finalize the write on Close).
 func saveJSON(s Store, name string, v any) (err error) {
@@ -76,26 +161,53 @@ func loadJSON(s Store, name string, ptr any) error {
 	return nil
 }
 
-// countingWriter tracks bytes written.
+// readJSONFile decodes a local JSON file (e.g. a mapping passed on the
+// command line) into ptr.
+func readJSONFile(path string, ptr any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, ptr); err != nil {
+		return fmt.Errorf("decode %s: %w", path, err)
+	}
+	return nil
+}
+
+// countingWriter tracks bytes written, calling progress (if set) about every
+// progressInterval bytes.
 type countingWriter struct {
-	w io.Writer
-	n int64
+	w        io.Writer
+	n        int64
+	progress func(int64)
+	reported int64
 }
 
 func (c *countingWriter) Write(p []byte) (int, error) {
 	n, err := c.w.Write(p)
 	c.n += int64(n)
+	if c.progress != nil && c.n-c.reported >= progressInterval {
+		c.reported = c.n
+		c.progress(c.n)
+	}
 	return n, err
 }
 
-// countingReader tracks bytes read.
+// countingReader tracks bytes read, calling progress (if set) about every
+// progressInterval bytes.
 type countingReader struct {
-	r io.Reader
-	n int64
+	r        io.Reader
+	n        int64
+	progress func(int64)
+	reported int64
 }
 
 func (c *countingReader) Read(p []byte) (int, error) {
 	n, err := c.r.Read(p)
 	c.n += int64(n)
+	if c.progress != nil && c.n-c.reported >= progressInterval {
+		c.reported = c.n
+		c.progress(c.n)
+	}
 	return n, err
 }
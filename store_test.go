@@ -3,56 +3,14 @@ package pg_mini
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
-	"sync"
+	"io/fs"
+	"path/filepath"
+	"slices"
 	"testing"
 )
 
-// memStore is an in-memory Store, demonstrating that pg_mini can back
-// exports/imports with something other than the local filesystem. It is also
-// used by TestE2E_CustomStore to prove the seam end-to-end.
-type memStore struct {
-	mu    sync.Mutex
-	files map[string][]byte
-}
-
-func newMemStore() *memStore {
-	return &memStore{files: map[string][]byte{}}
-}
-
-func (m *memStore) Create(name string) (io.WriteCloser, error) {
-	return &memWriter{store: m, name: name}, nil
-}
-
-func (m *memStore) Open(name string) (io.ReadCloser, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	data, ok := m.files[name]
-	if !ok {
-		return nil, &memNotExist{name: name}
-	}
-	return io.NopCloser(bytes.NewReader(data)), nil
-}
-
-type memWriter struct {
-	store *memStore
-	name  string
-	buf   bytes.Buffer
-}
-
-func (w *memWriter) Write(p []byte) (int, error) { return w.buf.Write(p) }
-
-func (w *memWriter) Close() error {
-	w.store.mu.Lock()
-	defer w.store.mu.Unlock()
-	w.store.files[w.name] = append([]byte(nil), w.buf.Bytes()...)
-	return nil
-}
-
-type memNotExist struct{ name string }
-
-func (e *memNotExist) Error() string { return "memStore: not found: " + e.name }
-
 func TestDirStore_RoundTrip(t *testing.T) {
 	s := DirStore(t.TempDir())
 
@@ -82,6 +40,100 @@ func TestDirStore_RoundTrip(t *testing.T) {
 	}
 }
 
+func TestDirStore_Extended(t *testing.T) {
+	s := DirStore(filepath.Join(t.TempDir(), "backup")).(ExtendedStore)
+
+	// A directory that does not exist yet lists as empty
+	if names, err := s.List(""); err != nil || len(names) != 0 {
+		t.Fatalf("list missing dir: got %v, %v", names, err)
+	}
+
+	writeMember(t, s, "company.csv", "id\n")
+	writeMember(t, s, "nested/company.csv", "id\n")
+	writeMember(t, s, "website.csv", "id\n\"1\"\n")
+
+	names, err := s.List("")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if !slices.Equal(names, []string{"company.csv", "nested/company.csv", "website.csv"}) {
+		t.Fatalf("list: got %v", names)
+	}
+	if names, _ := s.List("nested/"); !slices.Equal(names, []string{"nested/company.csv"}) {
+		t.Fatalf("list nested/: got %v", names)
+	}
+
+	info, err := s.Stat("website.csv")
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if info.Size() != 7 || info.ModTime().IsZero() {
+		t.Fatalf("stat: got size %d, modtime %v", info.Size(), info.ModTime())
+	}
+	if _, err := s.Stat("missing.csv"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("stat missing: got %v, want fs.ErrNotExist", err)
+	}
+
+	if err := s.Delete("company.csv"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := s.Delete("company.csv"); err != nil {
+		t.Fatalf("delete missing: %v", err)
+	}
+	if names, _ := s.List("company"); len(names) != 0 {
+		t.Fatalf("list after delete: got %v", names)
+	}
+}
+
+// listOnlyStore fails Stat, to check that callers list instead of probing
+// every name.
+type listOnlyStore struct {
+	*MemStore
+}
+
+func (listOnlyStore) Stat(name string) (fs.FileInfo, error) {
+	return nil, errors.New("unexpected Stat " + name)
+}
+
+func Test_removeStaleFiles(t *testing.T) {
+	s := NewMemStore()
+	for _, name := range []string{
+		"company.csv", "company.rejected.csv", "website.csv", "old_table.csv", "data.sql",
+		"import_report.json", "rejected.json", "schema.json", "notes.txt",
+	} {
+		writeMember(t, s, name, "x")
+	}
+	// Rewritten by the current export
+	writeMember(t, s, "company.parquet", "x")
+	writeMember(t, s, "website.parquet", "x")
+
+	prev := &manifest{
+		Tables: []manifestTable{
+			{Table: "company", File: "company.csv", Format: FormatCSV},
+			{Table: "website", File: "website.csv", Format: FormatCSV},
+			{Table: "old_table", File: "old_table.csv", Format: FormatCSV},
+		},
+		Script: "data.sql",
+	}
+	cur := &manifest{Tables: []manifestTable{
+		{Table: "company", File: "company.parquet", Format: FormatParquet},
+		{Table: "website", File: "website.parquet", Format: FormatParquet},
+	}}
+	schema := &Schema{Tables: map[string]tableSchema{"company": {}, "website": {}}}
+
+	removed, err := removeStaleFiles(listOnlyStore{s}, prev, cur, schema)
+	if err != nil {
+		t.Fatalf("removeStaleFiles: %v", err)
+	}
+	want := []string{"company.csv", "company.rejected.csv", "data.sql", "import_report.json", "old_table.csv", "rejected.json", "website.csv"}
+	if !slices.Equal(removed, want) {
+		t.Errorf("removed: got %v, want %v", removed, want)
+	}
+	if names, _ := s.List(""); !slices.Equal(names, []string{"company.parquet", "notes.txt", "schema.json", "website.parquet"}) {
+		t.Errorf("left: got %v", names)
+	}
+}
+
 func TestStore_JSONRoundTrip(t *testing.T) {
 	type payload struct {
 		Name  string
@@ -89,7 +141,7 @@ func TestStore_JSONRoundTrip(t *testing.T) {
 	}
 	want := payload{Name: "orders", Count: 42}
 
-	s := newMemStore()
+	s := NewMemStore()
 	if err := saveJSON(s, "meta.json", want); err != nil {
 		t.Fatalf("saveJSON: %v", err)
 	}
@@ -103,6 +155,45 @@ func TestStore_JSONRoundTrip(t *testing.T) {
 	}
 }
 
+func TestMemStore(t *testing.T) {
+	s := NewMemStore()
+	writeMember(t, s, "schema.json", `{}`)
+	writeMember(t, s, "company.bin", "PGCOPY\n\xff")
+
+	if _, err := s.Open("missing.csv"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("open missing: got %v, want fs.ErrNotExist", err)
+	}
+	if got, _ := s.List(""); !slices.Equal(got, []string{"company.bin", "schema.json"}) {
+		t.Fatalf("list: got %v", got)
+	}
+
+	// The snapshot is unaffected by later writes
+	snap := s.Snapshot()
+	writeMember(t, s, "schema.json", `{"Tables":{}}`)
+	writeMember(t, s, "report.json", `{}`)
+	if got := readMember(t, snap, "schema.json"); got != `{}` {
+		t.Fatalf("snapshot schema.json: got %q", got)
+	}
+	if got, _ := snap.List(""); len(got) != 2 {
+		t.Fatalf("snapshot list: got %v", got)
+	}
+
+	var buf bytes.Buffer
+	if err := snap.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON: %v", err)
+	}
+	want := `{
+  "company.bin": {
+    "Base64": "UEdDT1BZCv8="
+  },
+  "schema.json": "{}"
+}
+`
+	if buf.String() != want {
+		t.Fatalf("WriteJSON: got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
 // TestE2E_CustomStore runs a full export → import round-trip through an
 // in-memory Store backend, with no artifacts ever touching the filesystem.
 func TestE2E_CustomStore(t *testing.T) {
@@ -117,7 +208,7 @@ func TestE2E_CustomStore(t *testing.T) {
 	execSQLFile(t, setupConn, "testdata/e2e/company/setup.sql")
 	original := snapshotDB(t, setupConn)
 
-	store := newMemStore()
+	store := NewMemStore()
 
 	exp := &Export{
 		DB:           connect(t, connStr),
@@ -0,0 +1,179 @@
+package pg_mini
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// UpsertPolicy configures the INSERT ... ON CONFLICT clause of one table in
+// Upsert and SoftInsert mode, for both the bulk and the row-by-row paths.
+// Column names are target column names (after any Mapping).
+type UpsertPolicy struct {
+	// Constraint names the unique constraint used as conflict target
+	// (ON CONFLICT ON CONSTRAINT ...). Takes precedence over ConflictCols.
+	// Its columns are looked up in the target and, like ConflictCols, left
+	// out of the default Update list.
+	Constraint string
+	// ConflictCols is the conflict target. Defaults to the primary key, else
+	// the first unique constraint.
+	ConflictCols []string
+	// Update lists the columns updated on conflict. Defaults to every
+	// imported column outside the conflict target.
+	Update []string
+	// Keep lists columns that are never updated on conflict, e.g. local-only
+	// flags. Applied after Update.
+	Keep []string
+	// IfNewer only updates a row when the incoming value of this column is
+	// greater than the existing one, e.g. "updated_at".
+	IfNewer string
+	// Where only updates a row when this condition holds. The existing row is
+	// referenced by table name, the incoming row as EXCLUDED. Combined with
+	// IfNewer using AND.
+	Where string
+
+	// constraintCols are the columns of Constraint, see resolveConstraints.
+	constraintCols []string
+}
+
+// UpsertPolicies holds an UpsertPolicy per table, keyed by backup table name.
+type UpsertPolicies map[string]UpsertPolicy
+
+// LoadUpsertPolicies reads UpsertPolicies from a JSON file.
+func LoadUpsertPolicies(path string) (UpsertPolicies, error) {
+	var p UpsertPolicies
+	if err := readJSONFile(path, &p); err != nil {
+		return nil, fmt.Errorf("upsert policies: %w", err)
+	}
+	return p, nil
+}
+
+// resolveConstraints looks up the columns of every policy's Constraint on
+// the target table, so they are excluded from the update like ConflictCols.
+func (p UpsertPolicies) resolveConstraints(ctx context.Context, conn *pgx.Conn, mapping Mapping) (UpsertPolicies, error) {
+	resolved := UpsertPolicies{}
+	for tbl, policy := range p {
+		if policy.Constraint != "" {
+			cols, err := getConstraintCols(ctx, conn, mapping.target(tbl), policy.Constraint)
+			if err != nil {
+				return nil, fmt.Errorf("upsert policy: %w", err)
+			}
+			policy.constraintCols = cols
+		}
+		resolved[tbl] = policy
+	}
+	return resolved, nil
+}
+
+// getConstraintCols returns the columns of a table's constraint, in key order.
+func getConstraintCols(ctx context.Context, conn *pgx.Conn, table, constraint string) ([]string, error) {
+	query := `
+		SELECT a.attname
+		FROM pg_constraint c
+		JOIN pg_class t ON t.oid = c.conrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		CROSS JOIN LATERAL unnest(c.conkey) WITH ORDINALITY AS k(attnum, ord)
+		JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.attnum
+		WHERE n.nspname = 'public' AND t.relname = $1 AND c.conname = $2
+		ORDER BY k.ord
+	`
+
+	rows, err := conn.Query(ctx, query, table, constraint)
+	if err != nil {
+		return nil, fmt.Errorf("querying constraint %s on %s: %w", constraint, table, err)
+	}
+	cols, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("querying constraint %s on %s: %w", constraint, table, err)
+	}
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("constraint %s not found on %s", constraint, table)
+	}
+	return cols, nil
+}
+
+// validate checks that every table is in the backup and every referenced
+// column is inserted. Columns are checked against the queries, after mapping
+// and schema drift adaptation: updating a column that is not inserted would
+// overwrite it with NULL or its default. Tables without queries, such as
+// tables missing in the target, are not checked.
+func (p UpsertPolicies) validate(schema *Schema, queries []ImportTableQueries) error {
+	for tbl, policy := range p {
+		if _, ok := schema.Tables[tbl]; !ok {
+			return fmt.Errorf("upsert policy: table %s not in backup", tbl)
+		}
+		idx := slices.IndexFunc(queries, func(tq ImportTableQueries) bool { return tq.Table == tbl })
+		if idx < 0 {
+			continue
+		}
+		cols := queries[idx].insertCols
+
+		check := append(slices.Clone(policy.ConflictCols), policy.Update...)
+		check = append(check, policy.Keep...)
+		if policy.IfNewer != "" {
+			check = append(check, policy.IfNewer)
+		}
+		for _, col := range check {
+			if !slices.Contains(cols, col) {
+				return fmt.Errorf("upsert policy: column %s.%s is not imported", tbl, col)
+			}
+		}
+	}
+	return nil
+}
+
+// conflictTarget returns the ON CONFLICT target, or "" if there is none.
+// defaultCols are used when the policy names neither constraint nor columns.
+func (p UpsertPolicy) conflictTarget(defaultCols []string) (target string, cols []string) {
+	if p.Constraint != "" {
+		return "ON CONSTRAINT " + p.Constraint, p.constraintCols
+	}
+	cols = defaultCols
+	if len(p.ConflictCols) > 0 {
+		cols = p.ConflictCols
+	}
+	if len(cols) == 0 {
+		return "", nil
+	}
+	return fmt.Sprintf("(%s)", strings.Join(cols, ", ")), cols
+}
+
+// doUpdate returns the DO UPDATE / DO NOTHING clause for a table whose
+// inserted columns are insertCols.
+func (p UpsertPolicy) doUpdate(table string, insertCols, conflictCols []string) string {
+	updateCols := p.Update
+	if len(updateCols) == 0 {
+		for _, col := range insertCols {
+			if !slices.Contains(conflictCols, col) {
+				updateCols = append(updateCols, col)
+			}
+		}
+	}
+
+	var setClauses []string
+	for _, col := range updateCols {
+		if !slices.Contains(p.Keep, col) {
+			setClauses = append(setClauses, fmt.Sprintf("%s = EXCLUDED.%s", col, col))
+		}
+	}
+	if len(setClauses) == 0 {
+		return "DO NOTHING"
+	}
+
+	var conds []string
+	if p.IfNewer != "" {
+		conds = append(conds, fmt.Sprintf("EXCLUDED.%s > %s.%s", p.IfNewer, table, p.IfNewer))
+	}
+	if p.Where != "" {
+		conds = append(conds, fmt.Sprintf("(%s)", p.Where))
+	}
+
+	clause := "DO UPDATE SET " + strings.Join(setClauses, ", ")
+	if len(conds) > 0 {
+		clause += " WHERE " + strings.Join(conds, " AND ")
+	}
+	return clause
+}
@@ -0,0 +1,149 @@
+package pg_mini
+
+import (
+	"testing"
+)
+
+func Test_generateImportQueries_UpsertPolicy(t *testing.T) {
+	schema := &Schema{
+		Tables: map[string]tableSchema{
+			"product": {
+				Name: "product",
+				Cols: []columnSchema{
+					{Name: "id"},
+					{Name: "sku"},
+					{Name: "name"},
+					{Name: "local_flag"},
+					{Name: "updated_at"},
+				},
+				PrimaryKeyCols:    []string{"id"},
+				UniqueConstraints: [][]string{{"sku"}},
+			},
+			"variant": {
+				Name:           "variant",
+				Cols:           []columnSchema{{Name: "id"}, {Name: "product_id"}},
+				PrimaryKeyCols: []string{"id"},
+			},
+		},
+		Relations: []foreignKeyRelation{
+			{FromTable: "variant", FromColumn: "product_id", ToTable: "product", ToColumn: "id"},
+		},
+	}
+	graph, err := buildGraph(schema, "product")
+	if err != nil {
+		t.Fatalf("buildGraph: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		policy        UpsertPolicy
+		wantUpsert    string
+		wantRowUpsert string
+	}{
+		{
+			name:          "default",
+			wantUpsert:    "INSERT INTO product (id, sku, name, local_flag, updated_at) SELECT id, sku, name, local_flag, updated_at FROM tmp_import_product ON CONFLICT (id) DO UPDATE SET sku = EXCLUDED.sku, name = EXCLUDED.name, local_flag = EXCLUDED.local_flag, updated_at = EXCLUDED.updated_at;",
+			wantRowUpsert: "INSERT INTO product (id, sku, name, local_flag, updated_at) OVERRIDING SYSTEM VALUE VALUES ($1, $2, $3, $4, $5) ON CONFLICT (id) DO UPDATE SET sku = EXCLUDED.sku, name = EXCLUDED.name, local_flag = EXCLUDED.local_flag, updated_at = EXCLUDED.updated_at;",
+		},
+		{
+			name:          "conflict columns, keep and if newer",
+			policy:        UpsertPolicy{ConflictCols: []string{"sku"}, Keep: []string{"local_flag", "id"}, IfNewer: "updated_at"},
+			wantUpsert:    "INSERT INTO product (id, sku, name, local_flag, updated_at) SELECT id, sku, name, local_flag, updated_at FROM tmp_import_product ON CONFLICT (sku) DO UPDATE SET name = EXCLUDED.name, updated_at = EXCLUDED.updated_at WHERE EXCLUDED.updated_at > product.updated_at;",
+			wantRowUpsert: "INSERT INTO product (id, sku, name, local_flag, updated_at) OVERRIDING SYSTEM VALUE VALUES ($1, $2, $3, $4, $5) ON CONFLICT (sku) DO UPDATE SET name = EXCLUDED.name, updated_at = EXCLUDED.updated_at WHERE EXCLUDED.updated_at > product.updated_at;",
+		},
+		{
+			name:          "named constraint, update list and where",
+			policy:        UpsertPolicy{Constraint: "product_sku_key", Update: []string{"name"}, Where: "product.local_flag IS NOT TRUE"},
+			wantUpsert:    "INSERT INTO product (id, sku, name, local_flag, updated_at) SELECT id, sku, name, local_flag, updated_at FROM tmp_import_product ON CONFLICT ON CONSTRAINT product_sku_key DO UPDATE SET name = EXCLUDED.name WHERE (product.local_flag IS NOT TRUE);",
+			wantRowUpsert: "INSERT INTO product (id, sku, name, local_flag, updated_at) OVERRIDING SYSTEM VALUE VALUES ($1, $2, $3, $4, $5) ON CONFLICT ON CONSTRAINT product_sku_key DO UPDATE SET name = EXCLUDED.name WHERE (product.local_flag IS NOT TRUE);",
+		},
+		{
+			name:          "named constraint, default update",
+			policy:        UpsertPolicy{Constraint: "product_sku_key", constraintCols: []string{"sku"}, Keep: []string{"id"}},
+			wantUpsert:    "INSERT INTO product (id, sku, name, local_flag, updated_at) SELECT id, sku, name, local_flag, updated_at FROM tmp_import_product ON CONFLICT ON CONSTRAINT product_sku_key DO UPDATE SET name = EXCLUDED.name, local_flag = EXCLUDED.local_flag, updated_at = EXCLUDED.updated_at;",
+			wantRowUpsert: "INSERT INTO product (id, sku, name, local_flag, updated_at) OVERRIDING SYSTEM VALUE VALUES ($1, $2, $3, $4, $5) ON CONFLICT ON CONSTRAINT product_sku_key DO UPDATE SET name = EXCLUDED.name, local_flag = EXCLUDED.local_flag, updated_at = EXCLUDED.updated_at;",
+		},
+		{
+			name:          "everything kept",
+			policy:        UpsertPolicy{Update: []string{"name"}, Keep: []string{"name"}},
+			wantUpsert:    "INSERT INTO product (id, sku, name, local_flag, updated_at) SELECT id, sku, name, local_flag, updated_at FROM tmp_import_product ON CONFLICT (id) DO NOTHING;",
+			wantRowUpsert: "INSERT INTO product (id, sku, name, local_flag, updated_at) OVERRIDING SYSTEM VALUE VALUES ($1, $2, $3, $4, $5) ON CONFLICT (id) DO NOTHING;",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policies := UpsertPolicies{"product": tt.policy}
+			queries := generateImportQueries(graph, schema, importQueryOpts{Upsert: policies})
+			if err := policies.validate(schema, queries); err != nil {
+				t.Fatalf("validate: %v", err)
+			}
+
+			for _, tq := range queries {
+				if tq.Table != "product" {
+					continue
+				}
+				if tq.Upsert != tt.wantUpsert {
+					t.Errorf("Upsert:\n  want: %s\n  got:  %s", tt.wantUpsert, tq.Upsert)
+				}
+				if tq.RowUpsert != tt.wantRowUpsert {
+					t.Errorf("RowUpsert:\n  want: %s\n  got:  %s", tt.wantRowUpsert, tq.RowUpsert)
+				}
+			}
+		})
+	}
+}
+
+func TestUpsertPolicies_validate(t *testing.T) {
+	schema := &Schema{
+		Tables: map[string]tableSchema{
+			"product": {Name: "product", Cols: []columnSchema{{Name: "id"}, {Name: "name"}, {Name: "legacy_code"}}, PrimaryKeyCols: []string{"id"}},
+			"variant": {Name: "variant", Cols: []columnSchema{{Name: "id"}, {Name: "product_id"}}, PrimaryKeyCols: []string{"id"}},
+		},
+		Relations: []foreignKeyRelation{
+			{FromTable: "variant", FromColumn: "product_id", ToTable: "product", ToColumn: "id"},
+		},
+	}
+	// The target dropped legacy_code, so DriftAdapt does not insert it
+	target := &Schema{
+		Tables: map[string]tableSchema{
+			"product": {Name: "product", Cols: []columnSchema{{Name: "id"}, {Name: "name"}}, PrimaryKeyCols: []string{"id"}},
+			"variant": {Name: "variant", Cols: []columnSchema{{Name: "id"}, {Name: "product_id"}}, PrimaryKeyCols: []string{"id"}},
+		},
+		Relations: []foreignKeyRelation{
+			{FromTable: "variant", FromColumn: "product_id", ToTable: "product", ToColumn: "id"},
+		},
+	}
+	graph, err := buildGraph(schema, "product")
+	if err != nil {
+		t.Fatalf("buildGraph: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		policies UpsertPolicies
+		target   *Schema
+		wantErr  bool
+	}{
+		{name: "valid", policies: UpsertPolicies{"product": {Update: []string{"name", "legacy_code"}}}},
+		{name: "valid after drift", policies: UpsertPolicies{"product": {Update: []string{"name"}}}, target: target},
+		{name: "unknown table", policies: UpsertPolicies{"nope": {}}, wantErr: true},
+		{name: "unknown conflict column", policies: UpsertPolicies{"product": {ConflictCols: []string{"sku"}}}, wantErr: true},
+		{name: "unknown update column", policies: UpsertPolicies{"product": {Update: []string{"sku"}}}, wantErr: true},
+		{name: "unknown if newer column", policies: UpsertPolicies{"product": {IfNewer: "updated_at"}}, wantErr: true},
+		{name: "update column dropped by drift", policies: UpsertPolicies{"product": {Update: []string{"legacy_code"}}}, target: target, wantErr: true},
+		{name: "keep column dropped by drift", policies: UpsertPolicies{"product": {Keep: []string{"legacy_code"}}}, target: target, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queries := generateImportQueries(graph, schema, importQueryOpts{Upsert: tt.policies, Target: tt.target})
+			err := tt.policies.validate(schema, queries)
+			if tt.wantErr && err == nil {
+				t.Error("expected error")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("validate: %v", err)
+			}
+		})
+	}
+}